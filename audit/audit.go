@@ -0,0 +1,97 @@
+// Package audit records what roo has done - Which credentials it issued, and what it did with them - as an
+// append-only log of JSON lines, and optionally to syslog.
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/jkueh/roo/logging"
+)
+
+// Modes that roo can use credentials in.
+const (
+	ModeExec         = "exec"
+	ModeConsole      = "console"
+	ModeConsoleURL   = "console-url"
+	ModeWriteProfile = "write-profile"
+)
+
+// Event is a single entry in the audit log.
+type Event struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Hostname string    `json:"hostname"`
+	// Mode is what the credentials were used for - One of the Mode* constants.
+	Mode     string `json:"mode"`
+	RoleName string `json:"role_name"`
+	RoleARN  string `json:"role_arn"`
+	// SessionARN is the ARN of the assumed role session, e.g. arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1
+	SessionARN string `json:"session_arn,omitempty"`
+	// SourceIdentity is the ARN of the identity that assumed the role, e.g. arn:aws:iam::000000000000:user/someone
+	SourceIdentity string `json:"source_identity,omitempty"`
+	// CacheHit is true if cached credentials were used, and false if they were refreshed.
+	CacheHit bool `json:"cache_hit"`
+	// Command is the command that was run (with secrets redacted) when Mode is ModeExec.
+	Command []string `json:"command,omitempty"`
+	// TargetProfile is the profile that was written to when Mode is ModeWriteProfile.
+	TargetProfile string `json:"target_profile,omitempty"`
+}
+
+// Logger writes audit events to a file, and optionally to syslog.
+type Logger struct {
+	filePath string
+	syslog   bool
+}
+
+// New returns a Logger that appends events to filePath (if not empty), and to syslog if useSyslog is true.
+func New(filePath string, useSyslog bool) *Logger {
+	return &Logger{filePath: filePath, syslog: useSyslog}
+}
+
+// Log records event, filling in the time, user and hostname if they haven't been set. The command is redacted before
+// it's written anywhere.
+func (l *Logger) Log(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.User == "" {
+		if currentUser, err := user.Current(); err == nil {
+			event.User = currentUser.Username
+		}
+	}
+	if event.Hostname == "" {
+		event.Hostname, _ = os.Hostname()
+	}
+	event.Command = logging.RedactArgs(event.Command)
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if l.filePath != "" {
+		if err := appendLine(l.filePath, line); err != nil {
+			return err
+		}
+	}
+	if l.syslog {
+		return writeSyslog(string(line))
+	}
+	return nil
+}
+
+// appendLine appends line (and a newline) to the file at filePath, creating it if it doesn't exist.
+func appendLine(filePath string, line []byte) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
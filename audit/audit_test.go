@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogAppendsEvents(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audit.log")
+	logger := New(filePath, false)
+	for _, mode := range []string{ModeExec, ModeConsole} {
+		err := logger.Log(Event{Mode: mode, RoleARN: "arn:aws:iam::000000000000:role/ReadOnly",
+			Command: []string{"mysql", "--password=hunter2"}})
+		if err != nil {
+			t.Fatalf("Unable to log event: %s", err)
+		}
+	}
+
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines in the audit log, got %d", len(lines))
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("Unable to unmarshal audit log line: %s", err)
+	}
+	if event.Mode != ModeExec || event.Time.IsZero() || event.Command[1] != "--password=REDACTED" {
+		t.Errorf("Unexpected audit event: %+v", event)
+	}
+}
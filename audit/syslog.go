@@ -0,0 +1,15 @@
+//go:build !windows && !plan9
+
+package audit
+
+import "log/syslog"
+
+// writeSyslog writes message to the local syslog daemon (or journald, on systemd hosts).
+func writeSyslog(message string) error {
+	writer, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, "roo")
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	return writer.Notice(message)
+}
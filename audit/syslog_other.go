@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+func writeSyslog(message string) error {
+	return errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"github.com/jkueh/roo/audit"
+	"github.com/jkueh/roo/config"
+)
+
+// writeAuditEvent records event in the audit log, unless it's been disabled. Failing to write to the audit log isn't
+// fatal, but we do let the user know about it.
+func writeAuditEvent(conf *config.Config, event audit.Event) {
+	if conf.Audit.Disabled {
+		return
+	}
+	filePath := conf.Audit.File
+	if filePath == "" {
+		filePath = filepath.Join(filepath.Dir(configFile), "audit.log")
+	}
+	if err := audit.New(filePath, conf.Audit.Syslog).Log(event); err != nil {
+		slog.Warn("Unable to write to the audit log", "file", filePath, "error", err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+// keysDirName is the name of the directory (next to the config file) that the encrypted-file key backend keeps access
+// keys in.
+const keysDirName = "keys"
+
+// authSubcommands maps the name of each 'roo auth' subcommand to the function that runs it.
+var authSubcommands = map[string]func(args []string) int{
+	"add":    runAuthAdd,
+	"remove": runAuthRemove,
+	"rotate": runAuthRotate,
+}
+
+// runAuth implements 'roo auth', which manages the long-term access keys stored for auth sources.
+func runAuth(args []string) int {
+	if len(args) > 0 {
+		if subcommand, ok := authSubcommands[args[0]]; ok {
+			return subcommand(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: roo auth add|remove|rotate [flags] <auth source>")
+	return 2
+}
+
+// runAuthAdd implements 'roo auth add', which stores the long-term access key for an auth source in its key backend.
+func runAuthAdd(args []string) int {
+	flags := flag.NewFlagSet("auth add", flag.ExitOnError)
+	addCommonFlags(flags)
+	var fromEnv bool
+	flags.BoolVar(
+		&fromEnv,
+		"env",
+		false,
+		"Read the access key from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, rather than prompting for it.",
+	)
+	parseCommonFlags(flags, args)
+	conf, source := authSourceArg(flags)
+	if source == nil {
+		return 2
+	}
+
+	var accessKeyID, secretAccessKey string
+	var err error
+	if fromEnv {
+		accessKeyID, secretAccessKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	} else {
+		accessKeyID, secretAccessKey, err = promptForAccessKey(source.Name)
+	}
+	if err != nil {
+		fatal("Unable to read the access key", "error", err)
+	}
+	if err := validateAccessKey(accessKeyID, secretAccessKey); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	client := roo.New(conf, nil, nil)
+	client.OpenKeyStore = openKeyStore
+	applyNetworkConfig(client, conf)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Make sure that AWS accepts the key before storing it, so that mistakes don't surface the next time a role is
+	// assumed.
+	identity, err := client.CallerIdentity(ctx, aws.CredentialsProviderFunc(
+		func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey}, nil
+		},
+	))
+	if err != nil {
+		fatal("Unable to verify the access key with AWS", "error", err)
+	}
+	if err := client.StoreAccessKey(ctx, source.Name, accessKeyID, secretAccessKey); err != nil {
+		fatal("Unable to store the access key", "error", err)
+	}
+
+	fmt.Printf(
+		"Stored the access key for %s in %s - Auth source '%s' will use it from now on.\n",
+		aws.ToString(identity.Arn), source.KeyBackend, source.Name,
+	)
+	if source.Profile != "" {
+		fmt.Printf("You can now remove the credentials for profile '%s' from your AWS credentials file.\n", source.Profile)
+	}
+	return 0
+}
+
+// runAuthRemove implements 'roo auth remove', which deletes the long-term access key stored for an auth source.
+func runAuthRemove(args []string) int {
+	flags := flag.NewFlagSet("auth remove", flag.ExitOnError)
+	addCommonFlags(flags)
+	parseCommonFlags(flags, args)
+	conf, source := authSourceArg(flags)
+	if source == nil {
+		return 2
+	}
+
+	client := roo.New(conf, nil, nil)
+	client.OpenKeyStore = openKeyStore
+	if err := client.DeleteAccessKey(source.Name); err != nil {
+		fatal("Unable to remove the access key", "error", err)
+	}
+	fmt.Printf("Removed the access key for auth source '%s' from %s.\n", source.Name, source.KeyBackend)
+	return 0
+}
+
+// runAuthRotate implements 'roo auth rotate', which replaces the long-term access key for an auth source (or the
+// default auth source) with a new one.
+func runAuthRotate(args []string) int {
+	flags := flag.NewFlagSet("auth rotate", flag.ExitOnError)
+	addCommonFlags(flags)
+	var baseProfile, mfaSerial string
+	flags.StringVar(
+		&baseProfile,
+		"profile",
+		os.Getenv(envProfile),
+		"The base AWS config profile whose access key should be rotated. (env: "+envProfile+")",
+	)
+	flags.StringVar(
+		&mfaSerial,
+		"mfa-serial",
+		os.Getenv(envMFASerial),
+		"The serial ARN of the MFA device to use. (env: "+envMFASerial+")",
+	)
+	parseCommonFlags(flags, args)
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: roo auth rotate [flags] [auth source]")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	conf := loadConfig()
+	client := newClient(conf)
+	client.Profile = baseProfile
+	client.MFASerial = mfaSerial
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rotated, err := client.RotateAccessKey(ctx, flags.Arg(0), writeProfileAccessKey)
+	if rotated == nil {
+		fatal("Unable to rotate the access key", "error", err)
+	}
+	destination := "the key store"
+	if rotated.Profile != "" {
+		destination = fmt.Sprintf("profile '%s'", rotated.Profile)
+	}
+	fmt.Printf(
+		"Replaced access key %s for %s with %s, and saved it to %s.\n",
+		rotated.OldAccessKeyID, rotated.UserARN, rotated.NewAccessKeyID, destination,
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// writeProfileAccessKey writes an access key to the credentials of an AWS config profile, in the AWS credentials file.
+func writeProfileAccessKey(profile, accessKeyID, secretAccessKey string) error {
+	return setProfileCredentials(
+		awsCredentialsFile(),
+		profile,
+		credentialsSetting{"aws_access_key_id", accessKeyID},
+		credentialsSetting{"aws_secret_access_key", secretAccessKey},
+	)
+}
+
+// authSourceArg loads the config, and returns the auth source named by the only argument in flags. If there isn't
+// one (or it doesn't have a key backend), the problem is printed and a nil auth source is returned.
+func authSourceArg(flags *flag.FlagSet) (*config.Config, *config.AuthSource) {
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: roo %s [flags] <auth source>\n", flags.Name())
+		flags.PrintDefaults()
+		return nil, nil
+	}
+	conf := loadConfig()
+	source := conf.GetNamedAuthSource(flags.Arg(0))
+	if source == nil {
+		fmt.Fprintf(os.Stderr, "Auth source '%s' isn't configured (See auth_sources in %s).\n", flags.Arg(0), configFile)
+		return nil, nil
+	}
+	if source.KeyBackend == "" {
+		fmt.Fprintf(
+			os.Stderr,
+			"Auth source '%s' doesn't have a key_backend - Set it to one of: %s\n",
+			source.Name, strings.Join(cachedcredsprovider.KeyBackends, ", "),
+		)
+		return nil, nil
+	}
+	return conf, source
+}
+
+// promptForAccessKey asks the user (on the terminal) for the access key for the named auth source.
+func promptForAccessKey(sourceName string) (accessKeyID, secretAccessKey string, err error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return "", "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty.out, "Enter the access key for auth source '%s'.\n", sourceName)
+	if accessKeyID, err = tty.readLine("Access key ID"); err != nil {
+		return "", "", err
+	}
+	if secretAccessKey, err = tty.readSecret("Secret access key"); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(accessKeyID), secretAccessKey, nil
+}
+
+// validateAccessKey checks that an access key looks like a long-term IAM user access key.
+func validateAccessKey(accessKeyID, secretAccessKey string) error {
+	if accessKeyID == "" || secretAccessKey == "" {
+		return errors.New("both the access key ID and the secret access key are required")
+	}
+	if strings.HasPrefix(accessKeyID, "ASIA") {
+		return errors.New("that's a temporary access key - roo needs the long-term access key of an IAM user (AKIA...)")
+	}
+	return nil
+}
+
+// openKeyStore opens the store that access keys are kept in for backend. It's used as the roo.Client's OpenKeyStore.
+func openKeyStore(backend string) (cachedcredsprovider.Store, error) {
+	return cachedcredsprovider.NewKeyStore(backend, keysDir(), keyPassphrase)
+}
+
+// keysDir returns the directory that the encrypted-file key backend keeps access keys in.
+func keysDir() string {
+	return filepath.Join(filepath.Dir(configFile), keysDirName)
+}
+
+// keyPassphrase returns the passphrase for the encrypted-file key backend - From ROO_KEY_PASSPHRASE, or prompted for
+// on the terminal. If no access keys have been stored yet, the user is asked to enter it twice. It's only asked for
+// once per run.
+var keyPassphrase = sync.OnceValues(func() (string, error) {
+	if passphrase := os.Getenv(envKeyPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	tty, err := openTerminal()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	keys, err := cachedcredsprovider.NewEncryptedFileStore(keysDir(), nil).List()
+	if err != nil {
+		return "", err
+	}
+	if len(keys) > 0 {
+		return tty.readSecret("Passphrase for roo's key store")
+	}
+	passphrase, err := tty.readSecret("Choose a passphrase for roo's key store")
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := tty.readSecret("Enter the passphrase again")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirmation {
+		return "", errors.New("the passphrases don't match")
+	}
+	return passphrase, nil
+})
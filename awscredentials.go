@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// awsCredentialsFile returns the path of the AWS shared credentials file, as the AWS CLI and SDKs find it.
+func awsCredentialsFile() string {
+	if filePath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); filePath != "" {
+		return filePath
+	}
+	return filepath.Join(homeDir, ".aws", "credentials")
+}
+
+// credentialsSetting is a key and value in a profile in the AWS credentials file.
+type credentialsSetting struct {
+	key, value string
+}
+
+// setProfileCredentials sets keys in profile's section of the AWS credentials file at filePath, adding the section
+// (or the file) if it doesn't exist. Everything else in the file is left as it was. The file is replaced through a
+// temporary file, so that it's never left half-written - And secrets are never passed to another process, where they'd
+// show up in its command line.
+func setProfileCredentials(filePath, profile string, settings ...credentialsSetting) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	// Find the profile's section: [start, end) are the lines after its header, up to the next section.
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if strings.TrimSpace(strings.Trim(trimmed, "[]")) == profile {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+profile+"]")
+		start, end = len(lines), len(lines)
+	}
+
+	var missing []string
+	for _, setting := range settings {
+		newLine := setting.key + " = " + setting.value
+		found := false
+		for i := start; i < end; i++ {
+			key, _, ok := strings.Cut(lines[i], "=")
+			if ok && strings.TrimSpace(key) == setting.key {
+				lines[i] = newLine
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, newLine)
+		}
+	}
+	// Add any new settings after the last line of the section that isn't blank.
+	insertAt := end
+	for insertAt > start && strings.TrimSpace(lines[insertAt-1]) == "" {
+		insertAt--
+	}
+	lines = append(lines[:insertAt], append(missing, lines[insertAt:]...)...)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSetProfileCredentials(t *testing.T) {
+	for _, test := range []struct {
+		name, existing, expected string
+	}{
+		{
+			"replace",
+			"[default]\naws_access_key_id = AKIADEFAULT\n\n[base]\n# Rotated by roo\naws_access_key_id=AKIAOLD\n" +
+				"aws_secret_access_key = old-secret\nregion = eu-west-1\n\n[other]\naws_access_key_id = AKIAOTHER\n",
+			"[default]\naws_access_key_id = AKIADEFAULT\n\n[base]\n# Rotated by roo\naws_access_key_id = AKIANEW\n" +
+				"aws_secret_access_key = new-secret\nregion = eu-west-1\n\n[other]\naws_access_key_id = AKIAOTHER\n",
+		},
+		{
+			"add keys",
+			"[base]\nregion = eu-west-1\n\n[other]\n",
+			"[base]\nregion = eu-west-1\naws_access_key_id = AKIANEW\naws_secret_access_key = new-secret\n\n[other]\n",
+		},
+		{
+			"add profile",
+			"[default]\naws_access_key_id = AKIADEFAULT\n",
+			"[default]\naws_access_key_id = AKIADEFAULT\n\n[base]\naws_access_key_id = AKIANEW\n" +
+				"aws_secret_access_key = new-secret\n",
+		},
+		{
+			"new file",
+			"",
+			"[base]\naws_access_key_id = AKIANEW\naws_secret_access_key = new-secret\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), ".aws", "credentials")
+			if test.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filePath, []byte(test.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := setProfileCredentials(
+				filePath,
+				"base",
+				credentialsSetting{"aws_access_key_id", "AKIANEW"},
+				credentialsSetting{"aws_secret_access_key", "new-secret"},
+			)
+			if err != nil {
+				t.Fatalf("Unable to set the credentials: %s", err)
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", test.expected, data)
+			}
+			if info, err := os.Stat(filePath); runtime.GOOS != "windows" && (err != nil || info.Mode().Perm() != 0600) {
+				t.Errorf("Expected the file to be private, got %v (error: %v)", info.Mode(), err)
+			}
+		})
+	}
+}
@@ -3,11 +3,52 @@ package cachedcredsprovider
 import (
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
-// CachedCredentials represents the entire JSON blob that we store.
+// CachedCredentials are the credentials for an assumed role, along with where they came from. See Encode for how
+// they're stored.
 type CachedCredentials struct {
+	// RoleARN is the ARN of the role that was assumed.
+	RoleARN string
+	// SourceProfile is the AWS config profile that was used to assume the role, if any.
+	SourceProfile string
+	// IssuedAt is when the credentials were issued by STS.
+	IssuedAt  time.Time
 	ExpiresAt time.Time
-	Values    credentials.Value
+	Values    Credentials
+	// SessionARN is the ARN of the assumed role session that the credentials belong to.
+	SessionARN string
+	// SourceIdentity is the ARN of the identity that assumed the role.
+	SourceIdentity string
+	// SourceAccessKeyID is the long-term access key in SourceProfile that the credentials were (ultimately) issued
+	// with, and SourceAccessKeyCreatedAt is when it was created. They're only known if the auth source has an
+	// access_key_max_age, and are kept so that the key's age can be checked without asking IAM every time.
+	SourceAccessKeyID        string
+	SourceAccessKeyCreatedAt time.Time
+}
+
+// IsExpired returns true if the credentials have expired, or are due to expire within the refresh window.
+func (c *CachedCredentials) IsExpired() bool {
+	earlyExpiryTime := c.ExpiresAt.Add(-time.Second * time.Duration(refreshWindowSeconds))
+	return time.Now().After(earlyExpiryTime)
+}
+
+// AWSCredentials returns the credentials in the form used by aws-sdk-go-v2.
+func (c *CachedCredentials) AWSCredentials() aws.Credentials {
+	return aws.Credentials{
+		AccessKeyID:     c.Values.AccessKeyID,
+		SecretAccessKey: c.Values.SecretAccessKey,
+		SessionToken:    c.Values.SessionToken,
+		Source:          ProviderName,
+		CanExpire:       true,
+		Expires:         c.ExpiresAt,
+	}
+}
+
+// Credentials are the AWS credentials themselves.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
 }
@@ -0,0 +1,140 @@
+package cachedcredsprovider
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FormatVersion is the version of the cache format written by Encode. It must be incremented whenever a change is
+// made to cacheEntry that older versions of roo wouldn't understand.
+const FormatVersion = 1
+
+// UnsupportedVersionError is returned by Decode when the cached credentials were written in a format version that
+// this version of roo doesn't know about - Usually because they were written by a newer version of roo.
+type UnsupportedVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf(
+		"cached credentials are in format version %d, but this version of roo only supports up to version %d - "+
+			"upgrade roo, or delete the cached credentials",
+		e.Version, FormatVersion,
+	)
+}
+
+// cacheEntry is the JSON representation of CachedCredentials. See the Cache File Format section of the README.
+type cacheEntry struct {
+	Version        int                   `json:"version"`
+	RoleARN        string                `json:"role_arn"`
+	SessionARN     string                `json:"session_arn"`
+	SourceIdentity string                `json:"source_identity,omitempty"`
+	SourceProfile  string                `json:"source_profile,omitempty"`
+	IssuedAt       time.Time             `json:"issued_at"`
+	ExpiresAt      time.Time             `json:"expires_at"`
+	Credentials    cacheEntryCredentials `json:"credentials"`
+	// These are optional, so older versions of roo can ignore them.
+	SourceAccessKeyID        string     `json:"source_access_key_id,omitempty"`
+	SourceAccessKeyCreatedAt *time.Time `json:"source_access_key_created_at,omitempty"`
+}
+
+type cacheEntryCredentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+// legacyCachedCredentials is the gob-encoded struct that roo cached credentials as before the JSON format existed.
+type legacyCachedCredentials struct {
+	ExpiresAt      time.Time
+	Values         legacyCredentialsValue
+	SessionARN     string
+	SourceIdentity string
+}
+
+// legacyCredentialsValue mirrors the fields of aws-sdk-go's credentials.Value, which legacy cache files contain.
+type legacyCredentialsValue struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	ProviderName    string
+}
+
+// Encode - Serialises CachedCredentials into the (JSON) format that's stored in the cache.
+func Encode(cachedCredentials *CachedCredentials) ([]byte, error) {
+	entry := cacheEntry{
+		Version:        FormatVersion,
+		RoleARN:        cachedCredentials.RoleARN,
+		SessionARN:     cachedCredentials.SessionARN,
+		SourceIdentity: cachedCredentials.SourceIdentity,
+		SourceProfile:  cachedCredentials.SourceProfile,
+		IssuedAt:       cachedCredentials.IssuedAt,
+		ExpiresAt:      cachedCredentials.ExpiresAt,
+		Credentials: cacheEntryCredentials{
+			AccessKeyID:     cachedCredentials.Values.AccessKeyID,
+			SecretAccessKey: cachedCredentials.Values.SecretAccessKey,
+			SessionToken:    cachedCredentials.Values.SessionToken,
+		},
+		SourceAccessKeyID: cachedCredentials.SourceAccessKeyID,
+	}
+	if !cachedCredentials.SourceAccessKeyCreatedAt.IsZero() {
+		entry.SourceAccessKeyCreatedAt = &cachedCredentials.SourceAccessKeyCreatedAt
+	}
+	return json.MarshalIndent(entry, "", "  ")
+}
+
+// Decode - Deserialises CachedCredentials from the format that's stored in the cache. The legacy gob format is also
+// accepted, so that existing caches keep working. If the data is in a format version that's newer than
+// FormatVersion, an *UnsupportedVersionError is returned.
+func Decode(data []byte) (*CachedCredentials, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return decodeLegacy(data)
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("unable to parse cached credentials: %w", err)
+	}
+	if entry.Version < 1 || entry.Version > FormatVersion {
+		return nil, &UnsupportedVersionError{Version: entry.Version}
+	}
+	cachedCredentials := &CachedCredentials{
+		RoleARN:        entry.RoleARN,
+		SourceProfile:  entry.SourceProfile,
+		IssuedAt:       entry.IssuedAt,
+		ExpiresAt:      entry.ExpiresAt,
+		SessionARN:     entry.SessionARN,
+		SourceIdentity: entry.SourceIdentity,
+		Values: Credentials{
+			AccessKeyID:     entry.Credentials.AccessKeyID,
+			SecretAccessKey: entry.Credentials.SecretAccessKey,
+			SessionToken:    entry.Credentials.SessionToken,
+		},
+		SourceAccessKeyID: entry.SourceAccessKeyID,
+	}
+	if entry.SourceAccessKeyCreatedAt != nil {
+		cachedCredentials.SourceAccessKeyCreatedAt = *entry.SourceAccessKeyCreatedAt
+	}
+	return cachedCredentials, nil
+}
+
+// decodeLegacy decodes credentials that were cached in the legacy gob format.
+func decodeLegacy(data []byte) (*CachedCredentials, error) {
+	var legacy legacyCachedCredentials
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy); err != nil {
+		return nil, fmt.Errorf("unable to parse cached credentials in the legacy format: %w", err)
+	}
+	return &CachedCredentials{
+		ExpiresAt: legacy.ExpiresAt,
+		Values: Credentials{
+			AccessKeyID:     legacy.Values.AccessKeyID,
+			SecretAccessKey: legacy.Values.SecretAccessKey,
+			SessionToken:    legacy.Values.SessionToken,
+		},
+		SessionARN:     legacy.SessionARN,
+		SourceIdentity: legacy.SourceIdentity,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package cachedcredsprovider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	creds := &CachedCredentials{
+		RoleARN:        "arn:aws:iam::000000000000:role/ReadOnly",
+		SourceProfile:  "auth",
+		IssuedAt:       time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		ExpiresAt:      time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC),
+		Values:         Credentials{AccessKeyID: "ASIATEST", SecretAccessKey: "secret", SessionToken: "token"},
+		SessionARN:     "arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1",
+		SourceIdentity: "arn:aws:iam::999999999999:user/someone",
+
+		SourceAccessKeyID:        "AKIATEST",
+		SourceAccessKeyCreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	data, err := Encode(creds)
+	if err != nil {
+		t.Fatalf("Unable to encode credentials: %s", err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Unable to decode credentials: %s", err)
+	}
+	if *decoded != *creds {
+		t.Errorf("Decoded credentials don't match:\n%+v\n%+v", decoded, creds)
+	}
+}
+
+func TestDecodeRejectsUnknownVersions(t *testing.T) {
+	for _, data := range []string{`{"version": 2}`, `{"role_arn": "arn:aws:iam::000000000000:role/ReadOnly"}`} {
+		_, err := Decode([]byte(data))
+		var versionErr *UnsupportedVersionError
+		if !errors.As(err, &versionErr) {
+			t.Errorf("Expected an UnsupportedVersionError for %s, got %v", data, err)
+		}
+	}
+}
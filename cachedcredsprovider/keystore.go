@@ -0,0 +1,55 @@
+package cachedcredsprovider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BackendEncryptedFile stores each entry in a file that's encrypted with a passphrase. It's only available for key
+// stores (See NewKeyStore).
+const BackendEncryptedFile = "encrypted-file"
+
+// KeyBackends are the backends that long-term access keys can be stored in with NewKeyStore.
+var KeyBackends = []string{BackendSecretService, BackendKeyring, BackendPass, BackendEncryptedFile}
+
+// Where key stores keep their entries in backends that are shared with the credential cache, so that the two don't
+// get mixed up.
+const (
+	keyStoreKeyringPrefix = "roo-key:"
+	keyStoreService       = "roo-keys"
+	keyStorePassPrefix    = "roo-keys"
+)
+
+// NewKeyStore returns the Store that long-term access keys are kept in for backend. dir and passphrase are only used by
+// the encrypted-file backend. The file and memory backends aren't supported, as they'd defeat the purpose.
+func NewKeyStore(backend string, dir string, passphrase PassphraseFunc) (Store, error) {
+	switch backend {
+	case BackendSecretService:
+		store, err := NewSecretServiceStore()
+		if err != nil {
+			return nil, err
+		}
+		store.Service = keyStoreService
+		return store, nil
+	case BackendKeyring:
+		store, err := NewKeyringStore()
+		if err != nil {
+			return nil, err
+		}
+		store.Prefix = keyStoreKeyringPrefix
+		return store, nil
+	case BackendPass:
+		store, err := NewPassStore()
+		if err != nil {
+			return nil, err
+		}
+		store.Prefix = keyStorePassPrefix
+		return store, nil
+	case BackendEncryptedFile:
+		return NewEncryptedFileStore(dir, passphrase), nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown key backend '%s' - Access keys can be stored with %s", backend, strings.Join(KeyBackends, ", "),
+		)
+	}
+}
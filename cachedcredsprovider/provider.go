@@ -1,18 +1,22 @@
 package cachedcredsprovider
 
 import (
-	"encoding/gob"
-	"log"
+	"context"
+	"log/slog"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 )
 
+// ProviderName is the source reported to the AWS SDK for credentials from CachedCredProvider.
+const ProviderName = "RooCachedCredProvider"
+
 var refreshWindowSeconds int
-var cacheDir string
 
 func init() {
 	// Set a default refresh window
@@ -20,105 +24,183 @@ func init() {
 		// Default refreshWindow - 90 seconds prior to expiry.
 		refreshWindowSeconds = 90
 	}
-
-	// Set a default cache dir.
 }
 
-// CachedCredProvider is the custom credential provider that we use in the credential provider chain when creating
-// a new AWS SDK session.
-type CachedCredProvider struct {
-	cachedCredentials CachedCredentials
-	cacheFilePath     string
-	credentials.Provider
+// Cache is the part of Store that CachedCredProvider needs.
+type Cache interface {
+	// Get returns the credentials stored under key, or nil (and no error) if there aren't any.
+	Get(key string) (*CachedCredentials, error)
+	// Put stores creds under key, replacing anything that's already there.
+	Put(key string, creds *CachedCredentials) error
 }
 
-// New - Returns an instance of CachedCredProvider, requiring a cache file path.
-func New(filePath string) *CachedCredProvider {
-	newProvider := &CachedCredProvider{
-		cacheFilePath: filePath,
-	}
-
-	// Should also take this opportunity to load the credentials from disk.
-	newProvider.loadFromDisk()
+// RefreshFunc obtains new credentials when the cached ones have expired - e.g. by assuming a role with MFA.
+type RefreshFunc func(ctx context.Context) (*CachedCredentials, error)
 
-	return newProvider
+// CachedCredProvider is an aws.CredentialsProvider that returns cached credentials while they're valid, and calls its
+// RefreshFunc (then caches the result) when they're not. Use V1 for the aws-sdk-go (v1) equivalent. It's safe for
+// concurrent use.
+type CachedCredProvider struct {
+	cache   Cache
+	key     string
+	refresh RefreshFunc
+
+	mutex             sync.Mutex
+	cachedCredentials *CachedCredentials
+	// forceRefresh skips the cache on the next retrieval - See Expire.
+	forceRefresh bool
 }
 
-// Retrieve - Interface function that returns the credential values.
-func (p *CachedCredProvider) Retrieve() (credentials.Value, error) {
-	err := p.loadFromDisk()
-
-	return p.cachedCredentials.Values, err
+// New - Returns a CachedCredProvider for the credentials stored under key in cache, using refresh to obtain new ones.
+// cache may be nil, in which case credentials are only kept for the life of the provider.
+func New(cache Cache, key string, refresh RefreshFunc) *CachedCredProvider {
+	return &CachedCredProvider{cache: cache, key: key, refresh: refresh}
 }
 
-// IsExpired - Interface function to determine if credentials need to be refreshed.
-func (p *CachedCredProvider) IsExpired() bool {
-	earlyExpiryTime := p.cachedCredentials.ExpiresAt.Add(-time.Second * time.Duration(refreshWindowSeconds))
-	if time.Now().After(earlyExpiryTime) {
-		return true
+// Retrieve - Implements aws.CredentialsProvider.
+func (p *CachedCredProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	cachedCredentials, _, err := p.Get(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
 	}
-	return false
+	return cachedCredentials.AWSCredentials(), nil
 }
 
-func (p *CachedCredProvider) loadFromDisk() error {
+// Get returns valid credentials, along with whether they were refreshed (rather than loaded from the cache).
+func (p *CachedCredProvider) Get(ctx context.Context) (*CachedCredentials, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 
-	// Attempt to read the file - Nothing wrong if it doesn't exist.
-	if _, err := os.Stat(p.cacheFilePath); os.IsNotExist(err) {
-		return err
+	if p.cache != nil && !p.forceRefresh && (p.cachedCredentials == nil || p.cachedCredentials.IsExpired()) {
+		cachedCredentials, err := p.cache.Get(p.key)
+		if err != nil {
+			// The credentials can still be refreshed, so treat an unreadable cache entry as a cache miss.
+			slog.Warn("Unable to read cached credentials", "key", p.key, "error", err)
+		} else if cachedCredentials != nil {
+			p.cachedCredentials = cachedCredentials
+		}
+	}
+	if p.cachedCredentials != nil && !p.forceRefresh && !p.cachedCredentials.IsExpired() {
+		return p.cachedCredentials, false, nil
 	}
 
-	cacheFile, err := os.Open(p.cacheFilePath)
+	cachedCredentials, err := p.refresh(ctx)
 	if err != nil {
-		return err
+		return nil, false, err
+	}
+	p.cachedCredentials = cachedCredentials
+	p.forceRefresh = false
+	if p.cache != nil {
+		if err := p.cache.Put(p.key, cachedCredentials); err != nil {
+			// The new credentials are still good (and may have cost an MFA code), so only warn.
+			slog.Warn("Unable to cache credentials", "key", p.key, "error", err)
+		}
 	}
+	return cachedCredentials, true, nil
+}
 
-	fileDecoder := gob.NewDecoder(cacheFile)
+// IsExpired reports whether the next retrieval will refresh the credentials. Credentials are considered expired if
+// they're due to expire within the refresh window, or haven't been retrieved yet.
+func (p *CachedCredProvider) IsExpired() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.forceRefresh || p.cachedCredentials == nil || p.cachedCredentials.IsExpired()
+}
 
-	var allegedCachedCredentials CachedCredentials
-	err = fileDecoder.Decode(&allegedCachedCredentials)
-	if err != nil {
-		return err
+// ExpiresAt returns when the current credentials expire, or the zero time if credentials haven't been retrieved yet.
+func (p *CachedCredProvider) ExpiresAt() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.cachedCredentials == nil {
+		return time.Time{}
 	}
+	return p.cachedCredentials.ExpiresAt
+}
 
-	cacheFile.Close()
+// Expire forces the next retrieval to refresh the credentials, even if the cached ones are still valid.
+func (p *CachedCredProvider) Expire() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.forceRefresh = true
+}
 
-	// Set the credentials struct in the provider
-	p.cachedCredentials = allegedCachedCredentials
+// NewCachedCredentialsFromSTS - Transforms the STS AssumeRole output to a CachedCredentials struct.
+// sourceIdentity is the ARN of the identity that assumed the role.
+func NewCachedCredentialsFromSTS(output *sts.AssumeRoleOutput, sourceIdentity string) *CachedCredentials {
+	cachedCredentials := newCachedCredentials(output.Credentials, sourceIdentity)
+	if output.AssumedRoleUser != nil && output.AssumedRoleUser.Arn != nil {
+		cachedCredentials.SessionARN = *output.AssumedRoleUser.Arn
+	}
+	return cachedCredentials
+}
 
-	return nil
+// NewCachedCredentialsFromSessionToken - Transforms the STS GetSessionToken output to a CachedCredentials struct.
+// sourceIdentity is the ARN of the identity that the session is for.
+func NewCachedCredentialsFromSessionToken(output *sts.GetSessionTokenOutput, sourceIdentity string) *CachedCredentials {
+	return newCachedCredentials(output.Credentials, sourceIdentity)
 }
 
-// WriteNewCredentialsFromSTS - Will transform the STS credentials struct to a CachedCredentials struct then overwrite
-// current values, and write it all to disk.
-func (p *CachedCredProvider) WriteNewCredentialsFromSTS(c *sts.Credentials, filePath string) error {
+// NewCachedCredentialsFromWebIdentity - Transforms the STS AssumeRoleWithWebIdentity output to a CachedCredentials
+// struct. The source identity is the subject of the web identity token.
+func NewCachedCredentialsFromWebIdentity(output *sts.AssumeRoleWithWebIdentityOutput) *CachedCredentials {
+	cachedCredentials := newCachedCredentials(output.Credentials, aws.ToString(output.SubjectFromWebIdentityToken))
+	if output.AssumedRoleUser != nil && output.AssumedRoleUser.Arn != nil {
+		cachedCredentials.SessionARN = *output.AssumedRoleUser.Arn
+	}
+	return cachedCredentials
+}
+
+func newCachedCredentials(c *types.Credentials, sourceIdentity string) *CachedCredentials {
 	timeNow := time.Now()
 	latestValidTime := c.Expiration.Add(-time.Second * time.Duration(refreshWindowSeconds))
 	if timeNow.After(latestValidTime) {
-		log.Println("WARNING: New credentials to write to disk expire within the refresh window.")
-		log.Println("Time Now:             ", timeNow)
-		log.Println("Latest Valid Time:    ", latestValidTime)
-		log.Println("Credential Expiration:", c.Expiration)
+		slog.Warn("New credentials to write to disk expire within the refresh window",
+			"time_now", timeNow,
+			"latest_valid_time", latestValidTime,
+			"expiration", *c.Expiration,
+		)
 	}
 
-	p.cachedCredentials.ExpiresAt = *c.Expiration
-	p.cachedCredentials.Values = credentials.Value{
-		AccessKeyID:     *c.AccessKeyId,
-		SecretAccessKey: *c.SecretAccessKey,
-		SessionToken:    *c.SessionToken,
+	return &CachedCredentials{
+		IssuedAt:  timeNow,
+		ExpiresAt: *c.Expiration,
+		Values: Credentials{
+			AccessKeyID:     *c.AccessKeyId,
+			SecretAccessKey: *c.SecretAccessKey,
+			SessionToken:    *c.SessionToken,
+		},
+		SourceIdentity: sourceIdentity,
+	}
+}
+
+// ReadCredentialsFile - Reads CachedCredentials from filePath. If the file doesn't exist, the error will satisfy
+// os.IsNotExist.
+func ReadCredentialsFile(filePath string) (*CachedCredentials, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// WriteCredentialsFile - Writes CachedCredentials to filePath, creating it (with mode 0600) if required.
+func WriteCredentialsFile(filePath string, cachedCredentials *CachedCredentials) error {
+	data, err := Encode(cachedCredentials)
+	if err != nil {
+		return err
 	}
 
 	// Create the cachefile if it doesn't exist.
 	var cacheFile *os.File
-	var err error
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		cacheFile, err = os.Create(filePath)
 		if err != nil {
-			log.Println("WARNING: Unable to create cache file", filePath)
+			slog.Warn("Unable to create cache file", "file", filePath, "error", err)
 			return err
 		}
 	} else {
-		if cacheFile, err = os.OpenFile(filePath, os.O_WRONLY, os.ModeAppend); err != nil {
-			log.Println("WARNING: Unable to open cache file", filePath, "for writing:", err)
+		if cacheFile, err = os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, 0600); err != nil {
+			slog.Warn("Unable to open cache file for writing", "file", filePath, "error", err)
 			return err
 		}
 	}
@@ -127,21 +209,15 @@ func (p *CachedCredProvider) WriteNewCredentialsFromSTS(c *sts.Credentials, file
 	if runtime.GOOS != "windows" {
 		err = cacheFile.Chmod(0600)
 		if err != nil {
-			log.Println("WARNING: Unable to set the file mode on the cache file", filePath, "-", err)
+			slog.Warn("Unable to set the file mode on the cache file", "file", filePath, "error", err)
 		}
 	}
 
 	// Write to the cacheFile
-	gobEncoder := gob.NewEncoder(cacheFile)
-	err = gobEncoder.Encode(p.cachedCredentials)
-	if err != nil {
+	if _, err := cacheFile.Write(data); err != nil {
+		cacheFile.Close()
 		return err
 	}
 
 	return cacheFile.Close()
 }
-
-// GetCredentialExpiryTime - Returns the expiry time for these credentials.
-func (p *CachedCredProvider) GetCredentialExpiryTime() time.Time {
-	return p.cachedCredentials.ExpiresAt
-}
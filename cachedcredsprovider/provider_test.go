@@ -0,0 +1,113 @@
+package cachedcredsprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// countingRefresh returns a RefreshFunc that issues new credentials, and counts how many times it's called.
+func countingRefresh(calls *int) RefreshFunc {
+	return func(ctx context.Context) (*CachedCredentials, error) {
+		*calls++
+		return &CachedCredentials{
+			ExpiresAt: time.Now().Add(time.Hour),
+			Values:    Credentials{AccessKeyID: "ASIAREFRESHED"},
+		}, nil
+	}
+}
+
+func TestProviderUsesValidCachedCredentials(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("key", &CachedCredentials{
+		ExpiresAt: time.Now().Add(time.Hour),
+		Values:    Credentials{AccessKeyID: "ASIACACHED"},
+	})
+	var calls int
+
+	creds, err := New(store, "key", countingRefresh(&calls)).Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if creds.AccessKeyID != "ASIACACHED" || creds.Source != ProviderName || !creds.CanExpire || creds.Expired() {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no refreshes, got %d", calls)
+	}
+}
+
+func TestProviderRefreshesExpiredCredentials(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("key", &CachedCredentials{
+		ExpiresAt: time.Now().Add(time.Minute), // Within the refresh window.
+		Values:    Credentials{AccessKeyID: "ASIAEXPIRING"},
+	})
+	var calls int
+	provider := New(store, "key", countingRefresh(&calls))
+
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if creds.AccessKeyID != "ASIAREFRESHED" || calls != 1 {
+		t.Errorf("Expected refreshed credentials, got %+v after %d refreshes", creds, calls)
+	}
+	if stored, _ := store.Get("key"); stored == nil || stored.Values.AccessKeyID != "ASIAREFRESHED" {
+		t.Errorf("Expected refreshed credentials to be cached, got %+v", stored)
+	}
+
+	provider.Expire()
+	if !provider.IsExpired() {
+		t.Error("Expected the provider to be expired after calling Expire")
+	}
+	if _, refreshed, _ := provider.Get(context.Background()); !refreshed || calls != 2 {
+		t.Errorf("Expected Expire to force a refresh, got %d refreshes", calls)
+	}
+}
+
+func TestV1Provider(t *testing.T) {
+	var calls int
+	creds := credentials.NewCredentials(New(nil, "key", countingRefresh(&calls)).V1())
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if value.AccessKeyID != "ASIAREFRESHED" || value.ProviderName != ProviderName {
+		t.Errorf("Unexpected credentials: %+v", value)
+	}
+	if expiresAt, err := creds.ExpiresAt(); err != nil || expiresAt.Before(time.Now()) {
+		t.Errorf("Unexpected expiry time %s (error: %v)", expiresAt, err)
+	}
+}
+
+// brokenCache is a Cache that can't be read from or written to.
+type brokenCache struct{}
+
+func (brokenCache) Get(key string) (*CachedCredentials, error) {
+	return nil, errors.New("unable to decode the cache entry")
+}
+
+func (brokenCache) Put(key string, creds *CachedCredentials) error {
+	return errors.New("the disk is full")
+}
+
+func TestProviderToleratesCacheErrors(t *testing.T) {
+	var calls int
+	provider := New(brokenCache{}, "key", countingRefresh(&calls))
+
+	cached, refreshed, err := provider.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the refreshed credentials despite the cache errors, got: %s", err)
+	}
+	if cached.Values.AccessKeyID != "ASIAREFRESHED" || !refreshed || calls != 1 {
+		t.Errorf("Unexpected credentials: %+v (refreshed: %t, refreshes: %d)", cached, refreshed, calls)
+	}
+	if creds, err := provider.Retrieve(context.Background()); err != nil || creds.AccessKeyID != "ASIAREFRESHED" {
+		t.Errorf("Expected Retrieve to return the refreshed credentials, got %+v (error: %v)", creds, err)
+	}
+}
@@ -0,0 +1,37 @@
+package cachedcredsprovider
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// V1Provider adapts CachedCredProvider to aws-sdk-go (v1)'s credentials.Provider and credentials.Expirer (via the
+// embedded IsExpired and ExpiresAt), for tools that haven't moved to aws-sdk-go-v2 yet.
+type V1Provider struct {
+	*CachedCredProvider
+}
+
+// V1 returns p as an aws-sdk-go (v1) credentials provider. It shares p's cached credentials.
+func (p *CachedCredProvider) V1() *V1Provider {
+	return &V1Provider{p}
+}
+
+// Retrieve - Implements credentials.Provider.
+func (p *V1Provider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+// RetrieveWithContext - Implements credentials.ProviderWithContext.
+func (p *V1Provider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	cachedCredentials, _, err := p.Get(ctx)
+	if err != nil {
+		return credentials.Value{ProviderName: ProviderName}, err
+	}
+	return credentials.Value{
+		AccessKeyID:     cachedCredentials.Values.AccessKeyID,
+		SecretAccessKey: cachedCredentials.Values.SecretAccessKey,
+		SessionToken:    cachedCredentials.Values.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package cachedcredsprovider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Cache backends that can be selected with NewStore.
+const (
+	BackendFile          = "file"
+	BackendMemory        = "memory"
+	BackendKeyring       = "keyring"
+	BackendSecretService = "secret-service"
+	BackendPass          = "pass"
+)
+
+// ErrBackendUnsupported is returned by NewStore when a backend isn't available on this platform.
+var ErrBackendUnsupported = errors.New("cache backend is not supported on this platform")
+
+// Store persists CachedCredentials, keyed by role identity (e.g. 000000000000-ReadOnly).
+type Store interface {
+	Cache
+	// Delete removes the credentials stored under key. It's not an error if there aren't any.
+	Delete(key string) error
+	// List returns the keys of all stored credentials.
+	List() ([]string, error)
+}
+
+// NewStore returns the Store for backend. dir is only used by the file backend, and defaults to file if empty.
+func NewStore(backend string, dir string) (Store, error) {
+	switch backend {
+	case "", BackendFile:
+		return NewFileStore(dir), nil
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendKeyring:
+		return NewKeyringStore()
+	case BackendSecretService:
+		return NewSecretServiceStore()
+	case BackendPass:
+		return NewPassStore()
+	default:
+		return nil, fmt.Errorf("unknown cache backend '%s'", backend)
+	}
+}
@@ -0,0 +1,249 @@
+package cachedcredsprovider
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedFileVersion is the version of the format written by EncryptedFileStore.
+const encryptedFileVersion = 1
+
+// encryptedFileExtension is the extension of the files that EncryptedFileStore writes.
+const encryptedFileExtension = ".json"
+
+// The scrypt parameters used to derive encryption keys from the passphrase - The ones recommended for interactive
+// logins (See the scrypt package).
+const (
+	scryptN       = 32768
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 32
+	scryptKeyLen  = 32
+)
+
+// ErrWrongPassphrase is returned by EncryptedFileStore when an entry can't be decrypted with the passphrase.
+var ErrWrongPassphrase = errors.New("the passphrase is incorrect, or the file has been tampered with")
+
+// PassphraseFunc returns the passphrase for an EncryptedFileStore. It's only called when the passphrase is needed.
+type PassphraseFunc func() (string, error)
+
+// encryptedFile is the JSON representation of an entry in an EncryptedFileStore. The plaintext is the entry in the
+// cache format (See Encode), encrypted with AES-256-GCM using a key derived from the passphrase with scrypt. The
+// entry's key is used as additional authenticated data, so that files can't be swapped around.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileStore stores credentials as one file per key in a directory, encrypted with a passphrase. Every entry
+// should be encrypted with the same passphrase - Put refuses to store an entry if the passphrase doesn't decrypt the
+// existing ones.
+type EncryptedFileStore struct {
+	Dir        string
+	Passphrase PassphraseFunc
+
+	mutex      sync.Mutex
+	passphrase string
+}
+
+// NewEncryptedFileStore returns an EncryptedFileStore that stores files in dir (which is created if required), using
+// passphrase to get the passphrase when it's first needed.
+func NewEncryptedFileStore(dir string, passphrase PassphraseFunc) *EncryptedFileStore {
+	return &EncryptedFileStore{Dir: dir, Passphrase: passphrase}
+}
+
+// Path returns the path of the file that credentials for key are stored in.
+func (s *EncryptedFileStore) Path(key string) string {
+	return filepath.Join(s.Dir, key+encryptedFileExtension)
+}
+
+// getPassphrase returns the passphrase, calling Passphrase the first time it's needed.
+func (s *EncryptedFileStore) getPassphrase() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+	if s.Passphrase == nil {
+		return "", errors.New("no passphrase was provided for the encrypted file store")
+	}
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+	s.passphrase = passphrase
+	return passphrase, nil
+}
+
+// Get implements Store.
+func (s *EncryptedFileStore) Get(key string) (*CachedCredentials, error) {
+	data, err := os.ReadFile(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decryptFile(data, key, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt %s: %w", s.Path(key), err)
+	}
+	return Decode(plaintext)
+}
+
+// Put implements Store.
+func (s *EncryptedFileStore) Put(key string, creds *CachedCredentials) error {
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassphrase(key, passphrase); err != nil {
+		return err
+	}
+	plaintext, err := Encode(creds)
+	if err != nil {
+		return err
+	}
+	data, err := encryptFile(plaintext, key, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that the existing entry isn't lost if the write fails part way through.
+	tempPath := s.Path(key) + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, s.Path(key))
+}
+
+// checkPassphrase returns ErrWrongPassphrase if passphrase doesn't decrypt one of the entries other than key, so that
+// entries aren't stored with different passphrases by mistake.
+func (s *EncryptedFileStore) checkPassphrase(key, passphrase string) error {
+	keys, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, other := range keys {
+		if other == key {
+			continue
+		}
+		data, err := os.ReadFile(s.Path(other))
+		if err != nil {
+			return err
+		}
+		if _, err := decryptFile(data, other, passphrase); err != nil {
+			return fmt.Errorf("the passphrase doesn't match the one that %s was stored with: %w", other, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *EncryptedFileStore) Delete(key string) error {
+	if err := os.Remove(s.Path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *EncryptedFileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, encryptedFileExtension) {
+			keys = append(keys, strings.TrimSuffix(name, encryptedFileExtension))
+		}
+	}
+	return keys, nil
+}
+
+// encryptFile encrypts plaintext with a key derived from passphrase, returning the encryptedFile for it.
+func encryptFile(plaintext []byte, key, passphrase string) ([]byte, error) {
+	file := encryptedFile{
+		Version: encryptedFileVersion,
+		Salt:    make([]byte, scryptSaltLen),
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(&file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return nil, err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, []byte(key))
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// decryptFile returns the plaintext of an encryptedFile.
+func decryptFile(data []byte, key, passphrase string) ([]byte, error) {
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != encryptedFileVersion {
+		return nil, fmt.Errorf("unsupported encrypted file version %d", file.Version)
+	}
+	aead, err := newAEAD(&file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("the nonce is the wrong size")
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// newAEAD returns the AES-256-GCM cipher for file, with its key derived from passphrase.
+func newAEAD(file *encryptedFile, passphrase string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,101 @@
+package cachedcredsprovider
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileStoreExtension is the extension of the files that FileStore writes.
+const fileStoreExtension = ".json"
+
+// legacyFileStoreExtension is the extension of the gob files that roo used to write. They're migrated to JSON the
+// first time they're read.
+const legacyFileStoreExtension = ".gob"
+
+// FileStore stores credentials as one JSON file per key in a directory.
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore returns a FileStore that stores files in dir, which must already exist.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// Path returns the path of the file that credentials for key are stored in.
+func (s *FileStore) Path(key string) string {
+	return filepath.Join(s.Dir, key+fileStoreExtension)
+}
+
+// legacyPath returns the path of the gob file that credentials for key were stored in by older versions of roo.
+func (s *FileStore) legacyPath(key string) string {
+	return filepath.Join(s.Dir, key+legacyFileStoreExtension)
+}
+
+// Get implements Store. If there's a legacy gob file for key, it's rewritten in the current format.
+func (s *FileStore) Get(key string) (*CachedCredentials, error) {
+	creds, err := ReadCredentialsFile(s.Path(key))
+	if !os.IsNotExist(err) {
+		return creds, err
+	}
+
+	creds, err = ReadCredentialsFile(s.legacyPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := s.Put(key, creds); err != nil {
+		slog.Warn("Unable to migrate legacy cache file", "file", s.legacyPath(key), "error", err)
+	}
+	return creds, nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(key string, creds *CachedCredentials) error {
+	if err := WriteCredentialsFile(s.Path(key), creds); err != nil {
+		return err
+	}
+	// The legacy file is now out of date, so it shouldn't be left lying around.
+	if err := os.Remove(s.legacyPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(key string) error {
+	for _, path := range []string{s.Path(key), s.legacyPath(key)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		for _, extension := range []string{fileStoreExtension, legacyFileStoreExtension} {
+			if key := strings.TrimSuffix(name, extension); key != name && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,111 @@
+package cachedcredsprovider
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// keyringDescriptionPrefix is prepended to keys to form the description of the key in the kernel keyring.
+const keyringDescriptionPrefix = "roo:"
+
+// keyringPermissions grants all permissions to both the possessor and the owning user, so that later invocations
+// (which may not possess the key) can still read it. Group and other get nothing.
+const keyringPermissions = 0x3f3f0000
+
+// KeyringStore stores credentials in the Linux kernel's per-user keyring (See keyrings(7)). Keys never touch the disk,
+// and the kernel removes them once the credentials expire.
+type KeyringStore struct {
+	// Prefix is prepended to keys to form the descriptions of the keys in the keyring.
+	Prefix string
+}
+
+// NewKeyringStore returns a KeyringStore.
+func NewKeyringStore() (*KeyringStore, error) {
+	return &KeyringStore{Prefix: keyringDescriptionPrefix}, nil
+}
+
+// Get implements Store.
+func (s *KeyringStore) Get(key string) (*CachedCredentials, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", s.Prefix+key, 0)
+	if errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data, err := keyctlRead(id)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Put implements Store.
+func (s *KeyringStore) Put(key string, creds *CachedCredentials) error {
+	data, err := Encode(creds)
+	if err != nil {
+		return err
+	}
+	id, err := unix.AddKey("user", s.Prefix+key, data, unix.KEY_SPEC_USER_KEYRING)
+	if err != nil {
+		return err
+	}
+	if err := unix.KeyctlSetperm(id, keyringPermissions); err != nil {
+		return err
+	}
+	if timeout := time.Until(creds.ExpiresAt); timeout > 0 {
+		_, err = unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, int(timeout.Seconds())+1, 0, 0)
+	}
+	return err
+}
+
+// Delete implements Store.
+func (s *KeyringStore) Delete(key string) error {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", s.Prefix+key, 0)
+	if errors.Is(err, unix.ENOKEY) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = unix.KeyctlInt(unix.KEYCTL_UNLINK, id, unix.KEY_SPEC_USER_KEYRING, 0, 0)
+	return err
+}
+
+// List implements Store.
+func (s *KeyringStore) List() ([]string, error) {
+	data, err := keyctlRead(unix.KEY_SPEC_USER_KEYRING)
+	if err != nil {
+		return nil, err
+	}
+	// Reading a keyring returns an array of 32-bit key IDs.
+	var keys []string
+	for i := 0; i+4 <= len(data); i += 4 {
+		id := int(int32(uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24))
+		description, err := unix.KeyctlString(unix.KEYCTL_DESCRIBE, id)
+		if err != nil {
+			continue
+		}
+		// The description is formatted as type;uid;gid;perm;description
+		fields := strings.SplitN(description, ";", 5)
+		if len(fields) == 5 && fields[0] == "user" && strings.HasPrefix(fields[4], s.Prefix) {
+			keys = append(keys, strings.TrimPrefix(fields[4], s.Prefix))
+		}
+	}
+	return keys, nil
+}
+
+// keyctlRead returns the payload of the key (or keyring) with the given ID.
+func keyctlRead(id int) ([]byte, error) {
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
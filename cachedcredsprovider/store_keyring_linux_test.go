@@ -0,0 +1,31 @@
+package cachedcredsprovider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestKeyringStore(t *testing.T) {
+	store, err := NewKeyringStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep out of the way of roo's own keys (and any other test run).
+	store.Prefix = fmt.Sprintf("roo-test-%d:", os.Getpid())
+
+	// Containers often don't allow access to the kernel keyring.
+	if err := store.Put("probe", &CachedCredentials{}); errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) ||
+		errors.Is(err, unix.EACCES) || errors.Is(err, unix.EDQUOT) {
+		t.Skipf("The kernel keyring isn't available: %s", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("probe"); err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package cachedcredsprovider
+
+// KeyringStore is only supported on Linux - Everywhere else, every method returns ErrBackendUnsupported.
+type KeyringStore struct {
+	Prefix string
+}
+
+// NewKeyringStore returns ErrBackendUnsupported, as the kernel keyring is only available on Linux.
+func NewKeyringStore() (*KeyringStore, error) {
+	return nil, ErrBackendUnsupported
+}
+
+// Get implements Store.
+func (s *KeyringStore) Get(key string) (*CachedCredentials, error) {
+	return nil, ErrBackendUnsupported
+}
+
+// Put implements Store.
+func (s *KeyringStore) Put(key string, creds *CachedCredentials) error {
+	return ErrBackendUnsupported
+}
+
+// Delete implements Store.
+func (s *KeyringStore) Delete(key string) error {
+	return ErrBackendUnsupported
+}
+
+// List implements Store.
+func (s *KeyringStore) List() ([]string, error) {
+	return nil, ErrBackendUnsupported
+}
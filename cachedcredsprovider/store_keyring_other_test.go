@@ -0,0 +1,22 @@
+//go:build !linux
+
+package cachedcredsprovider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyringStoreUnsupported(t *testing.T) {
+	if _, err := NewStore(BackendKeyring, t.TempDir()); !errors.Is(err, ErrBackendUnsupported) {
+		t.Errorf("Expected ErrBackendUnsupported, got %v", err)
+	}
+	// A KeyringStore that didn't come from NewKeyringStore doesn't quietly keep credentials in memory.
+	store := &KeyringStore{}
+	if err := store.Put("corporate", &CachedCredentials{}); !errors.Is(err, ErrBackendUnsupported) {
+		t.Errorf("Expected ErrBackendUnsupported, got %v", err)
+	}
+	if _, err := store.Get("corporate"); !errors.Is(err, ErrBackendUnsupported) {
+		t.Errorf("Expected ErrBackendUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package cachedcredsprovider
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps credentials in memory, so they only last as long as the process does. It's safe for concurrent
+// use.
+type MemoryStore struct {
+	mutex       sync.Mutex
+	credentials map[string]CachedCredentials
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{credentials: map[string]CachedCredentials{}}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(key string) (*CachedCredentials, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	creds, ok := s.credentials[key]
+	if !ok {
+		return nil, nil
+	}
+	return &creds, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(key string, creds *CachedCredentials) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.credentials[key] = *creds
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.credentials, key)
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]string, 0, len(s.credentials))
+	for key := range s.credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,106 @@
+package cachedcredsprovider
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// passPrefix is the folder in the password store that roo keeps credentials in.
+const passPrefix = "roo"
+
+// PassStore stores credentials in the standard unix password manager, pass (https://www.passwordstore.org), which
+// encrypts them with gpg.
+type PassStore struct {
+	// Command is the path to pass.
+	Command string
+	// Dir is the root of the password store - Used to list entries without decrypting them.
+	Dir string
+	// Prefix is the folder in the password store that the entries are kept in.
+	Prefix string
+}
+
+// NewPassStore returns a PassStore, provided that pass can be found in the PATH.
+func NewPassStore() (*PassStore, error) {
+	command, err := exec.LookPath("pass")
+	if err != nil {
+		return nil, fmt.Errorf("the pass cache backend requires pass: %w", err)
+	}
+	dir := os.Getenv("PASSWORD_STORE_DIR")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(homeDir, ".password-store")
+	}
+	return &PassStore{Command: command, Dir: dir, Prefix: passPrefix}, nil
+}
+
+// name returns the name of the pass entry for key.
+func (s *PassStore) name(key string) string {
+	return s.Prefix + "/" + key
+}
+
+// exists reports whether there's an entry for key, without having to decrypt it.
+func (s *PassStore) exists(key string) bool {
+	_, err := os.Stat(filepath.Join(s.Dir, s.Prefix, key+".gpg"))
+	return err == nil
+}
+
+// Get implements Store.
+func (s *PassStore) Get(key string) (*CachedCredentials, error) {
+	if !s.exists(key) {
+		return nil, nil
+	}
+	output, err := exec.Command(s.Command, "show", s.name(key)).Output()
+	if err != nil {
+		return nil, commandError("pass show", err)
+	}
+	return Decode(output)
+}
+
+// Put implements Store.
+func (s *PassStore) Put(key string, creds *CachedCredentials) error {
+	data, err := Encode(creds)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(s.Command, "insert", "--multiline", "--force", s.name(key))
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	if _, err := cmd.Output(); err != nil {
+		return commandError("pass insert", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *PassStore) Delete(key string) error {
+	if !s.exists(key) {
+		return nil
+	}
+	if _, err := exec.Command(s.Command, "rm", "--force", s.name(key)).Output(); err != nil {
+		return commandError("pass rm", err)
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *PassStore) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, s.Prefix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".gpg") {
+			keys = append(keys, strings.TrimSuffix(entry.Name(), ".gpg"))
+		}
+	}
+	return keys, nil
+}
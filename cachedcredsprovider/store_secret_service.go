@@ -0,0 +1,108 @@
+package cachedcredsprovider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceAttribute is the attribute that identifies secrets stored by roo, so that they can be listed.
+const secretServiceAttribute = "service"
+
+// secretServiceService is the value of secretServiceAttribute on secrets stored by roo.
+const secretServiceService = "roo"
+
+// SecretServiceStore stores credentials with the freedesktop.org Secret Service (e.g. GNOME Keyring or KWallet), using
+// the secret-tool CLI from libsecret.
+type SecretServiceStore struct {
+	// Command is the path to secret-tool.
+	Command string
+	// Service is the value of the service attribute on the secrets, which separates them from other secrets.
+	Service string
+}
+
+// NewSecretServiceStore returns a SecretServiceStore, provided that secret-tool can be found in the PATH.
+func NewSecretServiceStore() (*SecretServiceStore, error) {
+	command, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, fmt.Errorf("the secret-service cache backend requires secret-tool (from libsecret): %w", err)
+	}
+	return &SecretServiceStore{Command: command, Service: secretServiceService}, nil
+}
+
+// attributes returns the secret-tool attributes that identify the secret for key.
+func (s *SecretServiceStore) attributes(key string) []string {
+	return []string{secretServiceAttribute, s.Service, "key", key}
+}
+
+// Get implements Store.
+func (s *SecretServiceStore) Get(key string) (*CachedCredentials, error) {
+	output, err := exec.Command(s.Command, append([]string{"lookup"}, s.attributes(key)...)...).Output()
+	if err != nil {
+		// secret-tool exits with 1 and no output if there's no matching secret.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+			return nil, nil
+		}
+		return nil, commandError("secret-tool lookup", err)
+	}
+	return Decode(output)
+}
+
+// Put implements Store.
+func (s *SecretServiceStore) Put(key string, creds *CachedCredentials) error {
+	data, err := Encode(creds)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"store", "--label", "roo credentials for " + key}, s.attributes(key)...)
+	cmd := exec.Command(s.Command, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	if _, err := cmd.Output(); err != nil {
+		return commandError("secret-tool store", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *SecretServiceStore) Delete(key string) error {
+	_, err := exec.Command(s.Command, append([]string{"clear"}, s.attributes(key)...)...).Output()
+	// As with lookup, clearing a secret that doesn't exist exits with 1.
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+		return nil
+	} else if err != nil {
+		return commandError("secret-tool clear", err)
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *SecretServiceStore) List() ([]string, error) {
+	cmd := exec.Command(s.Command, "search", "--all", secretServiceAttribute, s.Service)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, commandError("secret-tool search", err)
+	}
+	// secret-tool search prints the attributes of each item one per line (e.g. 'attribute.key = value') - to stdout or
+	// stderr, depending on the version.
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(append(output, stderr.Bytes()...)))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), " = ")
+		if found && name == "attribute.key" {
+			keys = append(keys, value)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// commandError wraps err, including anything the command wrote to stderr.
+func commandError(command string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%s failed: %w: %s", command, err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return fmt.Errorf("%s failed: %w", command, err)
+}
@@ -0,0 +1,230 @@
+package cachedcredsprovider
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store Store) {
+	creds := &CachedCredentials{
+		ExpiresAt:  time.Now().Add(time.Hour).Round(time.Second),
+		Values:     Credentials{AccessKeyID: "ASIATEST", SecretAccessKey: "secret", SessionToken: "token"},
+		SessionARN: "arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1",
+	}
+
+	missing, err := store.Get("000000000000-ReadOnly")
+	if err != nil || missing != nil {
+		t.Fatalf("Expected nothing to be stored yet, got %v (error: %v)", missing, err)
+	}
+
+	if err := store.Put("000000000000-ReadOnly", creds); err != nil {
+		t.Fatalf("Unable to store credentials: %s", err)
+	}
+	stored, err := store.Get("000000000000-ReadOnly")
+	if err != nil {
+		t.Fatalf("Unable to get stored credentials: %s", err)
+	}
+	if stored == nil || stored.Values != creds.Values || !stored.ExpiresAt.Equal(creds.ExpiresAt) {
+		t.Errorf("Stored credentials don't match: %+v", stored)
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("Unable to list stored credentials: %s", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"000000000000-ReadOnly"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if err := store.Delete("000000000000-ReadOnly"); err != nil {
+		t.Fatalf("Unable to delete stored credentials: %s", err)
+	}
+	if err := store.Delete("000000000000-ReadOnly"); err != nil {
+		t.Errorf("Deleting missing credentials should not be an error: %s", err)
+	}
+	if deleted, err := store.Get("000000000000-ReadOnly"); err != nil || deleted != nil {
+		t.Errorf("Expected credentials to be deleted, got %v (error: %v)", deleted, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, NewFileStore(t.TempDir()))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestNewStoreRejectsUnknownBackend(t *testing.T) {
+	if _, err := NewStore("floppy-disk", t.TempDir()); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+func TestFileStoreMigratesLegacyGobFiles(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	legacy := legacyCachedCredentials{
+		ExpiresAt:  time.Now().Add(time.Hour).Round(time.Second),
+		Values:     legacyCredentialsValue{AccessKeyID: "ASIALEGACY"},
+		SessionARN: "arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1",
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.legacyPath("000000000000-ReadOnly"), buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := store.Get("000000000000-ReadOnly")
+	if err != nil {
+		t.Fatalf("Unable to read legacy cache file: %s", err)
+	}
+	if creds == nil || creds.Values.AccessKeyID != "ASIALEGACY" || creds.SessionARN != legacy.SessionARN {
+		t.Fatalf("Unexpected credentials from legacy cache file: %+v", creds)
+	}
+
+	if _, err := os.Stat(store.legacyPath("000000000000-ReadOnly")); !os.IsNotExist(err) {
+		t.Errorf("Expected the legacy cache file to be removed, got %v", err)
+	}
+	migrated, err := os.ReadFile(store.Path("000000000000-ReadOnly"))
+	if err != nil {
+		t.Fatalf("Expected the cache file to be rewritten: %s", err)
+	}
+	if !strings.Contains(string(migrated), `"version": 1`) {
+		t.Errorf("Expected the cache file to be rewritten as JSON, got %s", migrated)
+	}
+}
+
+func staticPassphrase(passphrase string) PassphraseFunc {
+	return func() (string, error) {
+		return passphrase, nil
+	}
+}
+
+func TestEncryptedFileStore(t *testing.T) {
+	testStore(t, NewEncryptedFileStore(t.TempDir(), staticPassphrase("correct horse")))
+}
+
+func TestEncryptedFileStoreRequiresPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	store := NewEncryptedFileStore(dir, staticPassphrase("correct horse"))
+	creds := &CachedCredentials{Values: Credentials{AccessKeyID: "AKIATEST", SecretAccessKey: "very-secret"}}
+	if err := store.Put("corporate", creds); err != nil {
+		t.Fatalf("Unable to store credentials: %s", err)
+	}
+	data, err := os.ReadFile(store.Path("corporate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "very-secret") || strings.Contains(string(data), "AKIATEST") {
+		t.Errorf("Expected the credentials to be encrypted, got %s", data)
+	}
+
+	wrong := NewEncryptedFileStore(dir, staticPassphrase("battery staple"))
+	if _, err := wrong.Get("corporate"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := wrong.Put("other", creds); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected entries with a different passphrase to be refused, got %v", err)
+	}
+
+	// The key is authenticated, so entries can't be swapped around.
+	if err := os.Rename(store.Path("corporate"), store.Path("other")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("other"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a renamed entry to be rejected, got %v", err)
+	}
+}
+
+func TestNewKeyStoreRejectsUnencryptedBackends(t *testing.T) {
+	for _, backend := range []string{BackendFile, BackendMemory, ""} {
+		if _, err := NewKeyStore(backend, t.TempDir(), nil); err == nil {
+			t.Errorf("Expected an error for the %s backend", backend)
+		}
+	}
+}
+
+// fakeCommand writes script to an executable file in a temporary directory, returning its path. It's used to stand in
+// for the CLIs that some stores use.
+func fakeCommand(t *testing.T, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake commands are shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPassStore(t *testing.T) {
+	// The fake pass keeps entries unencrypted, in the same layout as the real one.
+	dir := t.TempDir()
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+	command := fakeCommand(t, "pass", `
+case "$1" in
+show) cat "$PASSWORD_STORE_DIR/$2.gpg" ;;
+insert) mkdir -p "$(dirname "$PASSWORD_STORE_DIR/$4.gpg")" && cat > "$PASSWORD_STORE_DIR/$4.gpg" ;;
+rm) rm "$PASSWORD_STORE_DIR/$3.gpg" ;;
+*) echo "unexpected arguments: $*" >&2; exit 2 ;;
+esac
+`)
+	store := &PassStore{Command: command, Dir: dir, Prefix: passPrefix}
+	testStore(t, store)
+
+	// Entries are stored as they're encoded, so that they can be read with 'pass show'.
+	if err := store.Put("corporate", &CachedCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, passPrefix, "corporate.gpg"))
+	if err != nil || !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("Expected the entry to be stored as JSON, got %s (error: %v)", data, err)
+	}
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	// The fake secret-tool keeps secrets in files named after their key, exiting with 1 (and no output) for missing
+	// secrets as the real one does. It prints search results to stderr, as some versions do.
+	dir := t.TempDir()
+	t.Setenv("FAKE_SECRETS_DIR", dir)
+	command := fakeCommand(t, "secret-tool", `
+case "$1 $2 $3 $4" in
+"lookup service roo key") cat "$FAKE_SECRETS_DIR/$5" 2>/dev/null || exit 1 ;;
+"clear service roo key") rm "$FAKE_SECRETS_DIR/$5" 2>/dev/null || exit 1 ;;
+"search --all service roo")
+	for f in "$FAKE_SECRETS_DIR"/*; do
+		[ -e "$f" ] && echo "attribute.key = ${f##*/}" >&2
+	done ;;
+"store --label roo credentials for $7 service") cat > "$FAKE_SECRETS_DIR/$7" ;;
+*) echo "unexpected arguments: $*" >&2; exit 2 ;;
+esac
+exit 0
+`)
+	store := &SecretServiceStore{Command: command, Service: secretServiceService}
+	testStore(t, store)
+
+	if err := store.Put("corporate", &CachedCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "corporate"))
+	if err != nil || !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("Expected the secret to be stored as JSON, got %s (error: %v)", data, err)
+	}
+
+	// Anything else that goes wrong is reported, along with what secret-tool had to say about it.
+	broken := &SecretServiceStore{Command: fakeCommand(t, "secret-tool", "echo 'no secret service' >&2; exit 1\n")}
+	if _, err := broken.Get("corporate"); err == nil || !strings.Contains(err.Error(), "no secret service") {
+		t.Errorf("Expected secret-tool's error, got %v", err)
+	}
+}
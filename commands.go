@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/logging"
+	"github.com/jkueh/roo/roo"
+	"github.com/jkueh/roo/util"
+)
+
+// usedCodesFileName is the name of the file in the cache dir that records the last MFA code used with each device.
+const usedCodesFileName = "mfa_used_codes.json"
+
+// defaultRequestTimeout is used when a timeout isn't set with -timeout, ROO_TIMEOUT or the config file.
+const defaultRequestTimeout = 30 * time.Second
+
+// subcommands maps the name of a subcommand (e.g. 'roo list') to the function that runs it. Each function is passed
+// the command line arguments that follow the subcommand name, and returns the exit code.
+var subcommands = map[string]func(args []string) int{
+	"list":   runList,
+	"doctor": runDoctor,
+	"whoami": runWhoami,
+	"auth":   runAuth,
+	"mfa":    runMFA,
+}
+
+// addCommonFlags registers the flags that are shared between roo itself and its subcommands.
+func addCommonFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&debug, "debug", debug, "Enables debug logging.")
+	flags.BoolVar(&verbose, "verbose", verbose, "Enables verbose logging.")
+	flags.StringVar(&configFile, "config", configFile, "The path to the config file. (env: "+envConfigFile+")")
+	flags.StringVar(&cacheDir, "cache-dir", cacheDir, "The directory to cache credentials in. (env: "+envCacheDir+")")
+	flags.StringVar(&logFormat, "log-format", logFormat, "The log format: text or json.")
+	flags.StringVar(&logFile, "log-file", logFile, "Writes logs to this file instead of stderr.")
+	flags.BoolVar(
+		&noPrompt,
+		"no-prompt",
+		noPrompt,
+		fmt.Sprintf("Never prompt - Fail with exit code %d if input (e.g. an MFA code) is needed.", exitPromptRequired),
+	)
+	flags.DurationVar(
+		&requestTimeout,
+		"timeout",
+		requestTimeout,
+		"The maximum time to wait for each request to AWS, e.g. 30s. (env: "+envTimeout+")",
+	)
+}
+
+// parseCommonFlags parses args with flags (which should have had addCommonFlags called on it), then sets up logging.
+func parseCommonFlags(flags *flag.FlagSet, args []string) {
+	flags.Parse(args)
+
+	level := slog.LevelWarn
+	if debug {
+		level = slog.LevelDebug
+	} else if verbose {
+		level = slog.LevelInfo
+	}
+
+	var logWriter io.Writer = os.Stderr
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to open log file:", err)
+			os.Exit(2)
+		}
+		logWriter = file
+	}
+	if err := logging.Setup(logWriter, logFormat, level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.Debug("Debug mode enabled")
+}
+
+// fatal logs msg (and any attributes) as an error, then exits. If any of the attributes is an error caused by roo being
+// unable to prompt the user, the exit code is exitPromptRequired.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && isPromptError(err) {
+			os.Exit(exitPromptRequired)
+		}
+	}
+	os.Exit(1)
+}
+
+// loadConfig ensures that the config and cache directories exist (migrating the legacy config dir and the recently
+// used roles if required), then loads the config file. This should be called once the flags have been parsed.
+func loadConfig() *config.Config {
+	// Only migrate the legacy config dir if we're using the default config file and cache locations, as the user may
+	// have deliberately pointed us at it (or elsewhere) with flags or environment variables.
+	if configFile == filepath.Join(configDir, "config.yaml") && cacheDir == defaultCacheDir(homeDir, configDir) {
+		migrated, err := migrateLegacyConfigDir(legacyConfigDir, configDir, cacheDir)
+		if err != nil {
+			slog.Warn("Unable to migrate the legacy config dir", "dir", legacyConfigDir, "error", err)
+		} else if migrated {
+			slog.Info("Migrated the legacy config dir", "dir", legacyConfigDir, "config_dir", configDir, "cache_dir", cacheDir)
+		}
+	}
+
+	oldRecentRolesFilePath := filepath.Join(filepath.Dir(configFile), recentRolesFileName)
+	if err := moveRecentRoles(oldRecentRolesFilePath, recentRolesFilePath()); err != nil {
+		slog.Debug("Unable to move the recently used roles file", "path", oldRecentRolesFilePath, "error", err)
+	}
+
+	err := ensureDir(filepath.Dir(configFile), configDir)
+	if err != nil {
+		fatal("Unable to create the config file directory", "error", err)
+	}
+	err = ensureDir(cacheDir, defaultCacheDir(homeDir, configDir))
+	if err != nil {
+		fatal("Unable to create cacheDir", "error", err)
+	}
+
+	return config.New(configFile)
+}
+
+// ensureDir creates dir if it doesn't exist. If it's roo's default directory for its purpose (defaultDir), its
+// permissions are also reset to 0700 - Directories that the user has pointed us at (e.g. -cache-dir /tmp) may be
+// shared, so they're left as they are.
+func ensureDir(dir, defaultDir string) error {
+	if filepath.Clean(dir) == filepath.Clean(defaultDir) {
+		return util.EnsureDirExists(dir, 0700)
+	}
+	return os.MkdirAll(dir, 0700)
+}
+
+// openCredentialStore returns the store that credentials are cached in, as configured by cache_backend.
+func openCredentialStore(conf *config.Config) cachedcredsprovider.Store {
+	store, err := cachedcredsprovider.NewStore(conf.CacheBackend, cacheDir)
+	if err != nil {
+		fatal("Unable to open the credential cache", "backend", conf.CacheBackend, "error", err)
+	}
+	return store
+}
+
+// newClient returns a roo.Client for conf, with the credential cache, MFA prompts and network settings configured.
+func newClient(conf *config.Config) *roo.Client {
+	client := roo.New(conf, openCredentialStore(conf), mfaPrompt(conf))
+	client.MFADeviceSelector = roo.MFADeviceSelectorFunc(selectMFADevice)
+	client.OpenKeyStore = openKeyStore
+	client.UsedCodes = roo.NewUsedCodes(filepath.Join(cacheDir, usedCodesFileName))
+	applyNetworkConfig(client, conf)
+	return client
+}
+
+// applyNetworkConfig sets the client's timeout, retry and clock skew settings from the command line and conf.
+func applyNetworkConfig(client *roo.Client, conf *config.Config) {
+	client.Timeout = conf.Network.Timeout
+	if requestTimeout != 0 {
+		client.Timeout = requestTimeout
+	}
+	if client.Timeout == 0 {
+		client.Timeout = defaultRequestTimeout
+	}
+	client.MaxAttempts = conf.Network.MaxAttempts
+	client.MaxBackoff = conf.Network.MaxBackoff
+	client.TimeSource = conf.Network.TimeSource
+	client.ClockSkewThreshold = conf.Network.ClockSkewThreshold
+}
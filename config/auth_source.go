@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// MFA code sources, as set by mfa_code_source.
+const (
+	// MFACodeSourcePrompt prompts the user for MFA codes on the terminal. It's the default.
+	MFACodeSourcePrompt = "prompt"
+	// MFACodeSourceCommand runs MFACodeCommand, and uses what it prints as the MFA code.
+	MFACodeSourceCommand = "command"
+	// MFACodeSourceTOTP generates MFA codes from the seed of a virtual MFA device, which is kept in the auth source's
+	// key backend (See 'roo mfa enroll -store-seed').
+	MFACodeSourceTOTP = "totp"
+)
+
+// Auth source types, as set by type.
+const (
+	// AuthSourceTypeIAMUser logs in with the long-term access key of an IAM user, and MFA. It's the default.
+	AuthSourceTypeIAMUser = "iam_user"
+	// AuthSourceTypeWebIdentity exchanges an OIDC token (e.g. from a CI system) for a role with
+	// AssumeRoleWithWebIdentity. No access key or MFA is needed.
+	AuthSourceTypeWebIdentity = "web_identity"
+)
+
+// DefaultMFASessionDuration is how long the MFA session for an auth source lasts, unless configured otherwise.
+const DefaultMFASessionDuration = 12 * time.Hour
+
+// AuthSource is an account that roles are assumed from, along with the MFA devices that are used to do so.
+type AuthSource struct {
+	Name string `yaml:"name"`
+	// Type is how the account is logged in to: iam_user (the default) or web_identity.
+	Type string `yaml:"type,omitempty"`
+	// WebIdentityTokenFile is the file that the OIDC token is read from, for web_identity sources. If neither it nor
+	// WebIdentityTokenCommand is set, AWS_WEB_IDENTITY_TOKEN_FILE is used.
+	WebIdentityTokenFile string `yaml:"web_identity_token_file,omitempty"`
+	// WebIdentityTokenCommand is the command that prints the OIDC token, for web_identity sources. It's run in the same
+	// way as MFACodeCommand.
+	WebIdentityTokenCommand string `yaml:"web_identity_token_command,omitempty"`
+	// RoleARN is the role that the OIDC token is exchanged for, for web_identity sources. Defaults to AWS_ROLE_ARN.
+	RoleARN string `yaml:"role_arn,omitempty"`
+	// Profile is the AWS config profile with credentials for the account. If empty, the AWS SDK's default credential
+	// chain is used.
+	Profile string `yaml:"profile,omitempty"`
+	// KeyBackend is where the long-term access key for the account is stored by 'roo auth add': secret-service,
+	// keyring, pass or encrypted-file. If it's set, the stored access key is used instead of the profile's credentials
+	// (though the profile's other settings, such as its region, still apply).
+	KeyBackend string `yaml:"key_backend,omitempty"`
+	// MFASerials are the serial ARNs of the MFA devices that can be used - If there's more than one, the user is asked
+	// which one they want to use.
+	MFASerials []string `yaml:"mfa_serials,omitempty"`
+	// MFACodeSource is where MFA codes come from: prompt (the default), command or totp.
+	MFACodeSource string `yaml:"mfa_code_source,omitempty"`
+	// MFACodeCommand is the command that prints the MFA code, for the command code source. It's run with 'sh -c'
+	// (or 'cmd /C' on Windows), with the serial of the MFA device in ROO_MFA_SERIAL.
+	MFACodeCommand string `yaml:"mfa_code_command,omitempty"`
+	// Region is the AWS region to use for STS. Defaults to the region of the profile, then us-east-1.
+	Region string `yaml:"region,omitempty"`
+	// Default makes this the auth source for roles that don't specify one.
+	Default bool `yaml:"default,omitempty"`
+	// MFASessionDuration is how long the MFA session (See MFASession) lasts. While it's valid, roles are assumed with it
+	// rather than with an MFA code. Defaults to DefaultMFASessionDuration.
+	MFASessionDuration time.Duration `yaml:"mfa_session_duration,omitempty"`
+	// SessionDuration is how long the session for RoleARN lasts, for web_identity sources. Defaults to an hour.
+	SessionDuration time.Duration `yaml:"session_duration,omitempty"`
+	// AccessKeyMaxAge is how old the account's access key can get before roo warns that it's due to be rotated (with
+	// 'roo auth rotate'). Zero disables the warning.
+	AccessKeyMaxAge time.Duration `yaml:"access_key_max_age,omitempty"`
+	// MFASession assumes roles through an MFA session (from GetSessionToken), so that one MFA code covers every role
+	// until it expires, rather than asking for an MFA code for each role. It's off by default, and always off when no
+	// auth sources are configured.
+	MFASession bool `yaml:"mfa_session,omitempty"`
+}
+
+// GetMFASessionDuration returns how long the MFA session for the source lasts.
+func (s *AuthSource) GetMFASessionDuration() time.Duration {
+	if s.MFASessionDuration > 0 {
+		return s.MFASessionDuration
+	}
+	return DefaultMFASessionDuration
+}
+
+// IsWebIdentity returns true if the source exchanges an OIDC token for a role, rather than using an IAM user.
+func (s *AuthSource) IsWebIdentity() bool {
+	return s.Type == AuthSourceTypeWebIdentity
+}
+
+// HasMFADevice returns true if serial is one of the source's MFA devices.
+func (s *AuthSource) HasMFADevice(serial string) bool {
+	for _, mfaSerial := range s.MFASerials {
+		if mfaSerial == serial {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAuthSource returns the auth source for role - The one it names, otherwise the default auth source. If role is
+// nil, the default auth source is returned.
+//
+// If no auth sources are configured (or none of them are the default), the default is built from default_profile and
+// mfa_serial, and has an empty name.
+func (c *Config) GetAuthSource(role *RoleConfig) (*AuthSource, error) {
+	if role != nil && role.AuthSource != "" {
+		if source := c.GetNamedAuthSource(role.AuthSource); source != nil {
+			return source, nil
+		}
+		return nil, fmt.Errorf("role '%s' uses auth source '%s', which isn't configured", role.Name, role.AuthSource)
+	}
+	for i := range c.AuthSources {
+		if c.AuthSources[i].Default {
+			return &c.AuthSources[i], nil
+		}
+	}
+
+	source := &AuthSource{Profile: c.DefaultProfile}
+	if c.MFASerial != "" {
+		source.MFASerials = []string{c.MFASerial}
+	}
+	return source, nil
+}
+
+// GetNamedAuthSource returns the configured auth source with the given name, or nil if there isn't one.
+func (c *Config) GetNamedAuthSource(name string) *AuthSource {
+	for i := range c.AuthSources {
+		if c.AuthSources[i].Name == name {
+			return &c.AuthSources[i]
+		}
+	}
+	return nil
+}
+
+// AuthSourceForMFADevice returns the first configured auth source that uses the MFA device with the given serial, or
+// nil if there isn't one.
+func (c *Config) AuthSourceForMFADevice(serial string) *AuthSource {
+	for i := range c.AuthSources {
+		if c.AuthSources[i].HasMFADevice(serial) {
+			return &c.AuthSources[i]
+		}
+	}
+	return nil
+}
+
+// validateAuthSources returns any problems with the auth sources, and the roles' references to them.
+func (c *Config) validateAuthSources() []error {
+	var problems []error
+	names := map[string]bool{}
+	var defaults int
+	for i, source := range c.AuthSources {
+		if source.Name == "" {
+			problems = append(problems, fmt.Errorf("auth source %d doesn't have a name", i+1))
+		} else if names[source.Name] {
+			problems = append(problems, fmt.Errorf("there's more than one auth source named '%s'", source.Name))
+		}
+		names[source.Name] = true
+		if source.Default {
+			defaults++
+		}
+		for _, serial := range source.MFASerials {
+			if !validMFASerial(serial) {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' has an MFA serial that isn't a valid MFA device ARN: %s", source.Name, serial,
+				))
+			}
+		}
+		switch source.Type {
+		case "", AuthSourceTypeIAMUser:
+		case AuthSourceTypeWebIdentity:
+			if source.WebIdentityTokenFile != "" && source.WebIdentityTokenCommand != "" {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' has both a web_identity_token_file and a web_identity_token_command", source.Name,
+				))
+			}
+			if len(source.MFASerials) > 0 || source.KeyBackend != "" || source.MFACodeSource != "" {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' is a web_identity source, which doesn't use MFA devices or access keys",
+					source.Name,
+				))
+			}
+			if source.MFASession || source.MFASessionDuration != 0 {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' is a web_identity source, which doesn't have an MFA session - Use session_duration",
+					source.Name,
+				))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("auth source '%s' has an unknown type: %s", source.Name, source.Type))
+		}
+		switch source.MFACodeSource {
+		case "", MFACodeSourcePrompt:
+		case MFACodeSourceCommand:
+			if source.MFACodeCommand == "" {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' uses the command MFA code source, but doesn't have an mfa_code_command",
+					source.Name,
+				))
+			}
+		case MFACodeSourceTOTP:
+			if source.KeyBackend == "" {
+				problems = append(problems, fmt.Errorf(
+					"auth source '%s' uses the totp MFA code source, but doesn't have a key_backend to keep the seed in",
+					source.Name,
+				))
+			}
+		default:
+			problems = append(problems, fmt.Errorf(
+				"auth source '%s' has an unknown mfa_code_source: %s", source.Name, source.MFACodeSource,
+			))
+		}
+	}
+	if defaults > 1 {
+		problems = append(problems, fmt.Errorf("more than one auth source is marked as the default"))
+	}
+	for _, role := range c.Roles {
+		if role.AuthSource != "" && !names[role.AuthSource] {
+			problems = append(problems, fmt.Errorf(
+				"role '%s' uses auth source '%s', which isn't configured", role.Name, role.AuthSource,
+			))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAuthSource(t *testing.T) {
+	c := &Config{
+		DefaultProfile: "legacy",
+		MFASerial:      "arn:aws:iam::000000000000:mfa/someone",
+		AuthSources: []AuthSource{
+			{Name: "corporate", Profile: "corporate"},
+			{Name: "partner", Profile: "partner", MFASessionDuration: time.Hour},
+		},
+	}
+
+	source, err := c.GetAuthSource(nil)
+	if err != nil || source.Name != "" || source.Profile != "legacy" || source.MFASession {
+		t.Errorf("Expected the default auth source to be built from default_profile, got %+v, %v", source, err)
+	}
+	if !source.HasMFADevice(c.MFASerial) {
+		t.Errorf("Expected the default auth source to use mfa_serial, got %v", source.MFASerials)
+	}
+
+	source, err = c.GetAuthSource(&RoleConfig{Name: "partner-admin", AuthSource: "partner"})
+	if err != nil || source.Name != "partner" || source.GetMFASessionDuration() != time.Hour {
+		t.Errorf("Expected the partner auth source, got %+v, %v", source, err)
+	}
+	if _, err := c.GetAuthSource(&RoleConfig{Name: "missing", AuthSource: "missing"}); err == nil {
+		t.Error("Expected an error for an auth source that isn't configured")
+	}
+
+	c.AuthSources[0].Default = true
+	source, _ = c.GetAuthSource(&RoleConfig{Name: "prod-readonly"})
+	if source.Name != "corporate" || source.GetMFASessionDuration() != DefaultMFASessionDuration {
+		t.Errorf("Expected the corporate auth source to be the default, got %+v", source)
+	}
+}
+
+func TestValidateAuthSources(t *testing.T) {
+	c := testConfig()
+	c.AuthSources = []AuthSource{
+		{Name: "corporate", Default: true, MFASerials: []string{"arn:aws:iam::000000000000:user/someone"}},
+		{Name: "corporate", Default: true, MFACodeSource: MFACodeSourceCommand},
+		{Name: "partner", MFACodeSource: "carrier-pigeon"},
+		{Name: "contractor", MFACodeSource: MFACodeSourceTOTP},
+		{Name: "ci", Type: AuthSourceTypeWebIdentity, RoleARN: "arn:aws:iam::000000000000:role/CI"},
+		{Name: "runner", Type: AuthSourceTypeWebIdentity, WebIdentityTokenFile: "token", WebIdentityTokenCommand: "cat token",
+			KeyBackend: "keyring", MFASessionDuration: time.Hour},
+		{Name: "robot", Type: "robot"},
+	}
+	c.Roles[0].AuthSource = "missing"
+	// The MFA serial, the duplicate name, the missing command, the unknown code source, the TOTP code source without a
+	// key backend, the web identity source with two tokens, a key backend and an MFA session duration, the unknown
+	// type, the two defaults and the role that uses an auth source that doesn't exist.
+	if problems := c.Validate(); len(problems) != 11 {
+		t.Errorf("Expected 11 problems, got %d: %v", len(problems), problems)
+	}
+}
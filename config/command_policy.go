@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CommandBlockedError is returned by CheckCommand when a role's command policy doesn't permit a command.
+type CommandBlockedError struct {
+	Role    string
+	Command []string
+	// Pattern is the denied command pattern that matched, if that's why the command was blocked.
+	Pattern string
+}
+
+func (e *CommandBlockedError) Error() string {
+	command := strings.Join(e.Command, " ")
+	if e.Pattern != "" {
+		return fmt.Sprintf("'%s' matches denied command '%s' for role '%s'", command, e.Pattern, e.Role)
+	}
+	return fmt.Sprintf("'%s' doesn't match any of the allowed commands for role '%s'", command, e.Role)
+}
+
+// CheckCommand returns a *CommandBlockedError if args (the command and its arguments) isn't permitted by the role's
+// allowed_commands and denied_commands. Denied commands take precedence over allowed commands, and if
+// allowed_commands is empty then anything that isn't denied is allowed.
+//
+// Denied commands are matched with CommandMatches, and allowed commands with the stricter CommandMatchesPrefix, so that
+// 'aws s3 ls' doesn't allow 'aws s3 rb s3://bucket ls'.
+//
+// This is a guardrail against accidents rather than a security boundary - 'sh -c "terraform destroy"' will get
+// straight past it.
+func (r *RoleConfig) CheckCommand(args []string) error {
+	for _, pattern := range r.DeniedCommands {
+		if CommandMatches(pattern, args) {
+			return &CommandBlockedError{Role: r.Name, Command: args, Pattern: pattern}
+		}
+	}
+	if len(r.AllowedCommands) == 0 {
+		return nil
+	}
+	for _, pattern := range r.AllowedCommands {
+		if CommandMatchesPrefix(pattern, args) {
+			return nil
+		}
+	}
+	return &CommandBlockedError{Role: r.Name, Command: args}
+}
+
+// commandNameMatches returns true if the first word of a pattern matches the name of the executable in args.
+func commandNameMatches(words, args []string) bool {
+	if len(words) == 0 || len(args) == 0 {
+		return false
+	}
+	matched, _ := path.Match(words[0], filepath.Base(args[0]))
+	return matched
+}
+
+// CommandMatches returns true if args matches pattern. A pattern is a space-separated list of words, each of which
+// can contain shell-style wildcards (e.g. 'aws s3 r*'). The first word must match the name of the executable (without
+// its directory), and the remaining words must appear in args in the same order - But not necessarily next to each
+// other, so that 'terraform destroy' also matches 'terraform -chdir=prod destroy'.
+func CommandMatches(pattern string, args []string) bool {
+	words := strings.Fields(pattern)
+	if !commandNameMatches(words, args) {
+		return false
+	}
+
+	wordIndex := 1
+	for _, arg := range args[1:] {
+		if wordIndex >= len(words) {
+			break
+		}
+		if matched, _ := path.Match(words[wordIndex], arg); matched {
+			wordIndex++
+		}
+	}
+	return wordIndex >= len(words)
+}
+
+// CommandMatchesPrefix returns true if args matches pattern, which has the same format as for CommandMatches. Unlike
+// CommandMatches, the remaining words must match the first of the non-flag args (those that don't start with a '-'),
+// in the same positions - So 'aws s3 ls' matches 'aws --debug s3 ls s3://bucket', but not 'aws s3 rb s3://bucket ls'.
+//
+// Flags that take a separate value (e.g. '--region ap-southeast-2') need to be written as '--region=ap-southeast-2'
+// if they come before the words in the pattern, as the value would otherwise be taken as one of the words.
+func CommandMatchesPrefix(pattern string, args []string) bool {
+	words := strings.Fields(pattern)
+	if !commandNameMatches(words, args) {
+		return false
+	}
+
+	wordIndex := 1
+	for _, arg := range args[1:] {
+		if wordIndex >= len(words) {
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if matched, _ := path.Match(words[wordIndex], arg); !matched {
+			return false
+		}
+		wordIndex++
+	}
+	return wordIndex >= len(words)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCommandMatches(t *testing.T) {
+	for _, testCase := range []struct {
+		pattern  string
+		args     []string
+		expected bool
+	}{
+		{"terraform destroy", []string{"terraform", "destroy"}, true},
+		{"terraform destroy", []string{"/usr/local/bin/terraform", "-chdir=prod", "destroy", "-auto-approve"}, true},
+		{"terraform destroy", []string{"terraform", "plan"}, false},
+		{"aws s3 rb", []string{"aws", "--region", "ap-southeast-2", "s3", "rb", "s3://bucket"}, true},
+		{"aws s3 rb", []string{"aws", "s3", "ls"}, false},
+		{"kubectl", []string{"kubectl", "get", "pods"}, true},
+		{"aws s3 r*", []string{"aws", "s3", "rm", "s3://bucket/key"}, true},
+		{"aws", []string{"awscli"}, false},
+	} {
+		if actual := CommandMatches(testCase.pattern, testCase.args); actual != testCase.expected {
+			t.Errorf("CommandMatches(%q, %q) returned %t, expected %t",
+				testCase.pattern, testCase.args, actual, testCase.expected)
+		}
+	}
+}
+
+func TestCommandMatchesPrefix(t *testing.T) {
+	for _, testCase := range []struct {
+		pattern  string
+		args     []string
+		expected bool
+	}{
+		{"aws s3 ls", []string{"aws", "s3", "ls"}, true},
+		{"aws s3 ls", []string{"/usr/local/bin/aws", "--debug", "s3", "ls", "s3://bucket"}, true},
+		{"aws s3 ls", []string{"aws", "--region=ap-southeast-2", "s3", "ls"}, true},
+		{"aws s3 ls", []string{"aws", "s3", "rb", "s3://ls-bucket"}, false},
+		{"aws s3 ls", []string{"aws", "s3", "rm", "--recursive", "s3://x/ls"}, false},
+		{"aws s3 ls", []string{"aws", "s3", "rb", "s3://bucket", "ls"}, false},
+		{"aws s3 ls", []string{"aws", "s3"}, false},
+		{"aws s3 l*", []string{"aws", "s3", "ls"}, true},
+		{"kubectl", []string{"kubectl", "delete", "namespace", "prod"}, true},
+	} {
+		if actual := CommandMatchesPrefix(testCase.pattern, testCase.args); actual != testCase.expected {
+			t.Errorf("CommandMatchesPrefix(%q, %q) returned %t, expected %t",
+				testCase.pattern, testCase.args, actual, testCase.expected)
+		}
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	role := &RoleConfig{
+		Name:            "break-glass",
+		AllowedCommands: []string{"aws", "kubectl get"},
+		DeniedCommands:  []string{"aws s3 rb"},
+	}
+	if err := role.CheckCommand([]string{"aws", "sts", "get-caller-identity"}); err != nil {
+		t.Errorf("Allowed command was blocked: %s", err)
+	}
+
+	var blockedErr *CommandBlockedError
+	if err := role.CheckCommand([]string{"aws", "s3", "rb", "s3://bucket"}); !errors.As(err, &blockedErr) {
+		t.Errorf("Denied command was not blocked: %s", err)
+	} else if blockedErr.Pattern != "aws s3 rb" {
+		t.Errorf("Unexpected pattern in error: %s", blockedErr.Pattern)
+	}
+	if err := role.CheckCommand([]string{"terraform", "apply"}); !errors.As(err, &blockedErr) {
+		t.Errorf("Command not in the allow list was not blocked: %s", err)
+	}
+	if err := role.CheckCommand([]string{"kubectl", "delete", "pod", "get"}); !errors.As(err, &blockedErr) {
+		t.Errorf("Command with an allowed word out of position was not blocked: %s", err)
+	}
+
+	if err := (&RoleConfig{}).CheckCommand([]string{"terraform", "destroy"}); err != nil {
+		t.Errorf("Role without a command policy blocked a command: %s", err)
+	}
+}
@@ -3,85 +3,243 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/jkueh/roo/util"
 	"gopkg.in/yaml.v2"
 )
 
-// Verbose - Package variable for extra logging
-var Verbose bool
-
-// Debug - Package variable for extra logging
-var Debug bool
+// Defaults for the MFA settings in the config file.
+const (
+	DefaultMFAAttempts   = 3
+	DefaultMFACodeLength = 6
+)
 
 // Config represents the config file.
 type Config struct {
 	DefaultProfile string       `yaml:"default_profile"`
 	MFASerial      string       `yaml:"mfa_serial"`
 	Roles          []RoleConfig `yaml:"roles"`
+	Audit          AuditConfig  `yaml:"audit,omitempty"`
+	// CacheBackend is where credentials are cached: file (the default), memory, keyring, secret-service or pass.
+	CacheBackend string `yaml:"cache_backend,omitempty"`
+	// Network controls how roo talks to AWS.
+	Network NetworkConfig `yaml:"network,omitempty"`
+	// MFAAttempts is how many MFA codes roo asks for (including any that AWS rejects) before giving up. Defaults to 3.
+	MFAAttempts int `yaml:"mfa_attempts,omitempty"`
+	// MFACodeLength is the number of digits in a code from the MFA device. Defaults to 6.
+	MFACodeLength int `yaml:"mfa_code_length,omitempty"`
+	// AuthSources are the accounts that roles can be assumed from. If none are configured (or none are the default),
+	// roles that don't name one are assumed from default_profile with mfa_serial.
+	AuthSources []AuthSource `yaml:"auth_sources,omitempty"`
+}
+
+// NetworkConfig controls timeouts and retries for requests to AWS.
+type NetworkConfig struct {
+	// Timeout limits how long each request can take. Defaults to 30 seconds.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// MaxAttempts is the maximum number of attempts made for each request. Defaults to 3.
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+	// MaxBackoff is the maximum delay between attempts, which otherwise grows exponentially. Defaults to 20 seconds.
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+	// TimeSource is a URL whose Date header is used to check the local clock. Defaults to the Date header of STS
+	// responses.
+	TimeSource string `yaml:"time_source,omitempty"`
+	// ClockSkewThreshold is how far the local clock can drift from AWS before roo warns about it. Defaults to 30
+	// seconds.
+	ClockSkewThreshold time.Duration `yaml:"clock_skew_threshold,omitempty"`
 }
 
-// New - Returns a hydrated instance of Config from configFile.
+// AuditConfig controls the audit log of credential issuance and command execution.
+type AuditConfig struct {
+	// Disabled turns off the audit log, which is on by default.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// File is the path of the audit log. Defaults to audit.log alongside the config file.
+	File string `yaml:"file,omitempty"`
+	// Syslog additionally sends audit events to the local syslog daemon (or journald).
+	Syslog bool `yaml:"syslog,omitempty"`
+}
+
+// New - Returns a hydrated instance of Config from configFile. If the file doesn't exist, an example config is
+// written in its place and the process exits. Any other error is fatal - Use Load to handle errors yourself.
 func New(filePath string) *Config {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		bootstrapConfig(filePath)
 	}
 
+	config, err := Load(filePath)
+	if err != nil {
+		slog.Error("An error occurred while trying to load the config file", "file", filePath, "error", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+// Load - Returns a hydrated instance of Config from the file at filePath.
+func Load(filePath string) (*Config, error) {
 	configFile, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalln(fmt.Sprintf(
-			"An error occurred while trying to open the config file '%s': %s", filePath, err,
-		))
+		return nil, err
 	}
 	defer configFile.Close()
 
 	// Attempt to read the file
 	configBytes, err := ioutil.ReadAll(configFile)
 	if err != nil {
-		log.Fatalln(fmt.Sprintf(
-			"An error occurred while trying to read the config file '%s': %s", filePath, err,
-		))
+		return nil, err
 	}
 
 	var config Config
 	err = yaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		log.Fatalln(fmt.Sprintf("An error occurred while trying to unmarshal config file '%s': %s", filePath, err))
+		return nil, fmt.Errorf("unable to unmarshal config file '%s': %w", filePath, err)
 	}
 
-	return &config
+	return &config, nil
 }
 
-// GetRole Returns a RoleConfig.
-func (c *Config) GetRole(searchString string) *RoleConfig {
-	// Search precedence: ARN, Name, then aliases as ordered.
-
+// GetRole Returns the RoleConfig that best matches searchString.
+//
+// Search precedence is: ARN, Name, aliases (case-insensitive), account ID and role name (e.g. 000000000000/ReadOnly),
+// then a unique prefix of a name or alias, then a unique fuzzy match of a name, alias or account name. If more
+// than one role matches at the first level that produces a match, an *AmbiguousRoleError is returned. If nothing
+// matches, a *RoleNotFoundError with suggestions is returned.
+//
+// Alternatively, searchString can be a tag selector (e.g. env=prod,team=data), which must match exactly one role. It's
+// only treated as one if it isn't the ARN, name or alias of a role.
+func (c *Config) GetRole(searchString string) (*RoleConfig, error) {
 	// Search roles by ARN first.
 	for _, roleConfig := range c.Roles {
 		if roleConfig.ARN == searchString {
-			return &roleConfig
+			return &roleConfig, nil
 		}
 	}
 
 	// Then search by name
 	for _, roleConfig := range c.Roles {
 		if roleConfig.Name == searchString {
-			return &roleConfig
+			return &roleConfig, nil
 		}
 	}
 
 	// Then search by alias.
 	for _, roleConfig := range c.Roles {
 		for _, alias := range roleConfig.Aliases {
-			if strings.ToLower(alias) == strings.ToLower(searchString) {
-				return &roleConfig
+			if strings.EqualFold(alias, searchString) {
+				return &roleConfig, nil
 			}
 		}
 	}
-	return &RoleConfig{}
+
+	// Then by tags, if it's a tag selector.
+	if tags, ok := ParseTagSelector(searchString); ok {
+		var matches []RoleConfig
+		for _, roleConfig := range c.Roles {
+			if roleConfig.MatchesTags(tags) {
+				matches = append(matches, roleConfig)
+			}
+		}
+		if role, err := c.singleMatch(searchString, matches); role != nil || err != nil {
+			return role, err
+		}
+		return nil, &RoleNotFoundError{Query: searchString}
+	}
+
+	// Then by account ID and role name.
+	if accountID, roleName, found := strings.Cut(searchString, "/"); found {
+		var matches []RoleConfig
+		for _, roleConfig := range c.Roles {
+			if roleConfig.AccountID() == accountID && strings.EqualFold(roleConfig.RoleName(), roleName) {
+				matches = append(matches, roleConfig)
+			}
+		}
+		if role, err := c.singleMatch(searchString, matches); role != nil || err != nil {
+			return role, err
+		}
+	}
+
+	// Then by a prefix of the name or an alias.
+	var prefixMatches []RoleConfig
+	for _, roleConfig := range c.Roles {
+		for _, field := range append([]string{roleConfig.Name}, roleConfig.Aliases...) {
+			if strings.HasPrefix(strings.ToLower(field), strings.ToLower(searchString)) {
+				prefixMatches = append(prefixMatches, roleConfig)
+				break
+			}
+		}
+	}
+	if role, err := c.singleMatch(searchString, prefixMatches); role != nil || err != nil {
+		return role, err
+	}
+
+	// And finally, a fuzzy match.
+	fuzzyMatches := c.searchRoles(searchString, (*RoleConfig).lookupFields)
+	if role, err := c.singleMatch(searchString, fuzzyMatches); role != nil || err != nil {
+		return role, err
+	}
+
+	return nil, &RoleNotFoundError{Query: searchString, Suggestions: c.suggestRoles(searchString)}
+}
+
+// singleMatch returns the role if matches contains exactly one role, an *AmbiguousRoleError if it contains more than
+// one, and nil for both if it's empty.
+func (c *Config) singleMatch(searchString string, matches []RoleConfig) (*RoleConfig, error) {
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, &AmbiguousRoleError{Query: searchString, Candidates: matches}
+	}
+}
+
+// suggestRoles returns the names and aliases that are within a small edit distance of searchString.
+func (c *Config) suggestRoles(searchString string) []string {
+	maxDistance := len(searchString) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	var suggestions []string
+	for _, roleConfig := range c.Roles {
+		for _, field := range append([]string{roleConfig.Name}, roleConfig.Aliases...) {
+			if levenshteinDistance(strings.ToLower(field), strings.ToLower(searchString)) <= maxDistance {
+				suggestions = append(suggestions, field)
+			}
+		}
+	}
+	return suggestions
+}
+
+// levenshteinDistance returns the number of single character edits required to turn a into b.
+func levenshteinDistance(a, b string) int {
+	aRunes, bRunes := []rune(a), []rune(b)
+	previous := make([]int, len(bRunes)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(aRunes); i++ {
+		current := make([]int, len(bRunes)+1)
+		current[0] = i
+		for j := 1; j <= len(bRunes); j++ {
+			cost := 1
+			if aRunes[i-1] == bRunes[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(bRunes)]
 }
 
 // bootstrapConfig will generate a generic config file, and exit.
@@ -94,6 +252,7 @@ func bootstrapConfig(filePath string) {
 				IsDefault: true,
 				ARN:       "arn:aws:iam::000000000000:role/DeleteOnly",
 				Aliases:   []string{"delete", "deleteprod"},
+				Protected: true,
 			},
 			{
 				Name:    "another_one_of_your_accounts",
@@ -103,23 +262,25 @@ func bootstrapConfig(filePath string) {
 		},
 	})
 	if err != nil {
-		log.Fatalln("Unable to marshal the example config struct into YAML.")
+		slog.Error("Unable to marshal the example config struct into YAML", "error", err)
+		os.Exit(1)
 	}
 
 	err = util.EnsureFileExists(filePath, 0600)
 	if err != nil {
-		log.Fatalln("Unable to create configFile:", filePath, err)
+		slog.Error("Unable to create configFile", "file", filePath, "error", err)
+		os.Exit(1)
 	}
 
 	file, err := os.OpenFile(filePath, os.O_WRONLY, os.ModeAppend)
 	if err != nil {
-		log.Fatalln("Unable to open config file to bootstrap:", err)
+		slog.Error("Unable to open config file to bootstrap", "file", filePath, "error", err)
+		os.Exit(1)
 	}
 
 	_, err = file.Write(exampleConfigYAML)
 	if err != nil {
-		log.Println("Unable to write example config to file:", filePath)
-		log.Println(err)
+		slog.Error("Unable to write example config to file", "file", filePath, "error", err)
 	}
 
 	fmt.Println("Hey there! I noticed you didn't have a configuration file, so I created one for you.")
@@ -130,31 +291,20 @@ func bootstrapConfig(filePath string) {
 	os.Exit(100)
 }
 
-// ListRoles outputs a list of configured roles.
-func (c *Config) ListRoles() {
-	var defaultCount int
-	if len(c.Roles) <= 0 {
-		fmt.Println("It looks like you haven't got any roles configured!")
-		return
-	}
-	for _, role := range c.Roles {
-		if role.IsDefault {
-			defaultCount++
-		}
-		fmt.Println("ARN:", role.ARN)
-		fmt.Println("Name:", role.Name)
-		if len(role.Aliases) > 0 {
-			fmt.Println("Aliases:")
-			for _, alias := range role.Aliases {
-				fmt.Println("  - " + alias)
-			}
-		}
-		fmt.Println()
+// GetMFAAttempts returns how many MFA codes to ask for before giving up.
+func (c *Config) GetMFAAttempts() int {
+	if c.MFAAttempts > 0 {
+		return c.MFAAttempts
 	}
-	if defaultCount > 1 {
-		fmt.Println("Hey, it looks like you've got more than one role flagged as default!")
-		fmt.Println("Well use the first one in the list if a role isn't specified via the -role argument.")
+	return DefaultMFAAttempts
+}
+
+// GetMFACodeLength returns the number of digits in a code from the MFA device.
+func (c *Config) GetMFACodeLength() int {
+	if c.MFACodeLength > 0 {
+		return c.MFACodeLength
 	}
+	return DefaultMFACodeLength
 }
 
 // GetDefaultRole returns the first role flagged as default.
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testConfig() *Config {
+	return &Config{Roles: []RoleConfig{
+		{
+			Name:    "something-prod-readonly",
+			ARN:     "arn:aws:iam::000000000000:role/ReadOnly",
+			Aliases: []string{"something-prod", "prod-readonly"},
+		},
+		{
+			Name:    "something-test-developer",
+			ARN:     "arn:aws:iam::111111111111:role/Developer",
+			Aliases: []string{"something-dev", "test-dev"},
+		},
+		{
+			Name:    "something-test-readonly",
+			ARN:     "arn:aws:iam::111111111111:role/ReadOnly",
+			Aliases: []string{"test-readonly"},
+		},
+	}}
+}
+
+func TestGetRoleExactMatches(t *testing.T) {
+	c := testConfig()
+	for query, expected := range map[string]string{
+		"arn:aws:iam::111111111111:role/Developer": "something-test-developer",
+		"something-test-readonly":                  "something-test-readonly",
+		"PROD-READONLY":                            "something-prod-readonly",
+		"111111111111/ReadOnly":                    "something-test-readonly",
+		"000000000000/readonly":                    "something-prod-readonly",
+	} {
+		role, err := c.GetRole(query)
+		if err != nil {
+			t.Errorf("Query '%s' returned an error: %s", query, err)
+		} else if role.Name != expected {
+			t.Errorf("Query '%s' returned %s, expected %s", query, role.Name, expected)
+		}
+	}
+}
+
+func TestGetRolePrefixAndFuzzyMatches(t *testing.T) {
+	c := testConfig()
+	if role, err := c.GetRole("test-d"); err != nil || role.Name != "something-test-developer" {
+		t.Errorf("Unique prefix did not match: %v, %s", role, err)
+	}
+	if role, err := c.GetRole("stdev"); err != nil || role.Name != "something-test-developer" {
+		t.Errorf("Unique fuzzy query did not match: %v, %s", role, err)
+	}
+}
+
+func TestGetRoleFuzzyMatchIgnoresARN(t *testing.T) {
+	// "iamdev" is a subsequence of the developer role's ARN, but not of any name or alias.
+	var notFoundErr *RoleNotFoundError
+	if role, err := testConfig().GetRole("iamdev"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Query matched through the characters of an ARN: %v, %s", role, err)
+	}
+	if roles := testConfig().SearchRoles("iamdev"); len(roles) != 1 || roles[0].Name != "something-test-developer" {
+		t.Errorf("Picker search did not match on the ARN: %v", roles)
+	}
+}
+
+func TestGetRoleAmbiguous(t *testing.T) {
+	var ambiguousErr *AmbiguousRoleError
+	_, err := testConfig().GetRole("something-test")
+	if !errors.As(err, &ambiguousErr) {
+		t.Fatalf("Ambiguous prefix did not return an AmbiguousRoleError: %s", err)
+	}
+	if len(ambiguousErr.Candidates) != 2 {
+		t.Errorf("Expected 2 candidates, got %d", len(ambiguousErr.Candidates))
+	}
+}
+
+func TestGetRoleNotFound(t *testing.T) {
+	var notFoundErr *RoleNotFoundError
+	_, err := testConfig().GetRole("tets-dev")
+	if !errors.As(err, &notFoundErr) {
+		t.Fatalf("Unknown role did not return a RoleNotFoundError: %s", err)
+	}
+	if len(notFoundErr.Suggestions) != 1 || notFoundErr.Suggestions[0] != "test-dev" {
+		t.Errorf("Unexpected suggestions: %v", notFoundErr.Suggestions)
+	}
+}
+
+func TestGetRoleByTags(t *testing.T) {
+	c := testConfig()
+	c.Roles[0].Tags = map[string]string{"env": "prod", "team": "data"}
+	c.Roles[1].Tags = map[string]string{"env": "test", "team": "data"}
+	c.Roles[2].Tags = map[string]string{"env": "test", "team": "data"}
+
+	if role, err := c.GetRole("env=prod,team=data"); err != nil || role.Name != "something-prod-readonly" {
+		t.Errorf("Tag selector did not match the expected role: %v, %s", role, err)
+	}
+	var ambiguousErr *AmbiguousRoleError
+	if _, err := c.GetRole("env=test"); !errors.As(err, &ambiguousErr) {
+		t.Errorf("Tag selector matching multiple roles did not return an AmbiguousRoleError: %s", err)
+	}
+	var notFoundErr *RoleNotFoundError
+	if _, err := c.GetRole("env=staging"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Tag selector matching no roles did not return a RoleNotFoundError: %s", err)
+	}
+
+	// Exact names and aliases win over tag selectors that look the same.
+	c.Roles[1].Aliases = append(c.Roles[1].Aliases, "env=prod")
+	if role, err := c.GetRole("env=prod"); err != nil || role.Name != "something-test-developer" {
+		t.Errorf("Alias that looks like a tag selector did not match: %v, %s", role, err)
+	}
+}
+
+func TestGetRoleIgnoresDescriptions(t *testing.T) {
+	c := testConfig()
+	c.Roles[0].Description = "Read-only access to the billing dashboards"
+	var notFoundErr *RoleNotFoundError
+	if _, err := c.GetRole("billing"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Query matching only a description did not return a RoleNotFoundError: %v", err)
+	}
+	if roles := c.SearchRoles("billing"); len(roles) != 1 || roles[0].Name != "something-prod-readonly" {
+		t.Errorf("Expected the picker's search to match the description, got %v", roles)
+	}
+}
+
+func TestLoadNetworkConfig(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(filePath, []byte("network:\n  timeout: 45s\n  max_attempts: 5\n  max_backoff: 1m\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := Load(filePath)
+	if err != nil {
+		t.Fatalf("Unable to load config: %s", err)
+	}
+	expected := NetworkConfig{Timeout: 45 * time.Second, MaxAttempts: 5, MaxBackoff: time.Minute}
+	if conf.Network != expected {
+		t.Errorf("Unexpected network config: %+v", conf.Network)
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AmbiguousRoleError is returned by GetRole when a query matches more than one role.
+type AmbiguousRoleError struct {
+	Query      string
+	Candidates []RoleConfig
+}
+
+func (e *AmbiguousRoleError) Error() string {
+	names := make([]string, 0, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		names = append(names, candidate.Name)
+	}
+	return fmt.Sprintf("'%s' matches more than one role: %s", e.Query, strings.Join(names, ", "))
+}
+
+// RoleNotFoundError is returned by GetRole when a query doesn't match any roles.
+type RoleNotFoundError struct {
+	Query string
+	// Suggestions contains the names or aliases of roles that are similar to Query.
+	Suggestions []string
+}
+
+func (e *RoleNotFoundError) Error() string {
+	message := fmt.Sprintf("unable to find role by name or alias: '%s'", e.Query)
+	if len(e.Suggestions) > 0 {
+		message += fmt.Sprintf(" - did you mean %s?", strings.Join(e.Suggestions, ", "))
+	}
+	return message
+}
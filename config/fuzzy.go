@@ -0,0 +1,98 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyScore reports whether every character of query appears in candidate in order (case-insensitively), along with
+// a score for how good the match is - Higher is better. Consecutive matches, and matches at the start of a word (e.g.
+// after a '-' or '/'), score higher than scattered ones.
+func FuzzyScore(query, candidate string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	candidateRunes := []rune(strings.ToLower(candidate))
+	if len(queryRunes) == 0 {
+		return 0, true
+	}
+
+	var score, queryIndex int
+	previousMatch := -2
+	for candidateIndex, r := range candidateRunes {
+		if queryIndex >= len(queryRunes) {
+			break
+		}
+		if r != queryRunes[queryIndex] {
+			continue
+		}
+		score++
+		if candidateIndex == previousMatch+1 {
+			score += 2
+		}
+		if candidateIndex == 0 || isWordSeparator(candidateRunes[candidateIndex-1]) {
+			score += 3
+		}
+		previousMatch = candidateIndex
+		queryIndex++
+	}
+	if queryIndex < len(queryRunes) {
+		return 0, false
+	}
+	return score, true
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// searchableFields returns the values of a role that the picker searches on.
+func (r *RoleConfig) searchableFields() []string {
+	return append([]string{r.Name, r.ARN, r.AccountName, r.Group, r.Description}, r.Aliases...)
+}
+
+// lookupFields returns the values of a role that GetRole fuzzy-matches on. It's a much narrower set than
+// searchableFields, as a typo in a role name would otherwise resolve through the characters of an ARN or description.
+func (r *RoleConfig) lookupFields() []string {
+	return append([]string{r.Name, r.AccountName}, r.Aliases...)
+}
+
+// fuzzyScore returns the best score of query against any of fields.
+func fuzzyScore(query string, fields []string) (int, bool) {
+	bestScore, matched := 0, false
+	for _, field := range fields {
+		if score, ok := FuzzyScore(query, field); ok && (!matched || score > bestScore) {
+			bestScore, matched = score, true
+		}
+	}
+	return bestScore, matched
+}
+
+// SearchRoles returns the roles that fuzzy-match query on their name, ARN (and therefore account ID), account name,
+// group, description or aliases, best match first. Roles that match equally well are returned in the order they're
+// configured.
+func (c *Config) SearchRoles(query string) []RoleConfig {
+	return c.searchRoles(query, (*RoleConfig).searchableFields)
+}
+
+// searchRoles is SearchRoles, matching on the values that fields returns for each role.
+func (c *Config) searchRoles(query string, fields func(*RoleConfig) []string) []RoleConfig {
+	type scoredRole struct {
+		role  RoleConfig
+		score int
+	}
+	var matches []scoredRole
+	for _, role := range c.Roles {
+		if score, ok := fuzzyScore(query, fields(&role)); ok {
+			matches = append(matches, scoredRole{role, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	roles := make([]RoleConfig, 0, len(matches))
+	for _, match := range matches {
+		roles = append(roles, match.role)
+	}
+	return roles
+}
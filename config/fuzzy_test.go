@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := FuzzyScore("pdro", "something-prod-readonly"); !ok {
+		t.Errorf("Subsequence query did not match")
+	}
+	if _, ok := FuzzyScore("xyz", "something-prod-readonly"); ok {
+		t.Errorf("Unrelated query matched")
+	}
+	wordStart, _ := FuzzyScore("pr", "something-prod-readonly")
+	scattered, _ := FuzzyScore("pr", "top-of-the-range")
+	if wordStart <= scattered {
+		t.Errorf("Match at the start of a word (%d) did not outscore a scattered match (%d)", wordStart, scattered)
+	}
+}
+
+func TestSearchRoles(t *testing.T) {
+	c := &Config{Roles: []RoleConfig{
+		{Name: "something-test-developer", ARN: "arn:aws:iam::111111111111:role/Developer"},
+		{Name: "something-prod-readonly", ARN: "arn:aws:iam::000000000000:role/ReadOnly", Aliases: []string{"prod"}},
+	}}
+	roles := c.SearchRoles("prod")
+	if len(roles) != 1 || roles[0].Name != "something-prod-readonly" {
+		t.Errorf("Unexpected search results for 'prod': %v", roles)
+	}
+	roles = c.SearchRoles("1111")
+	if len(roles) != 1 || roles[0].Name != "something-test-developer" {
+		t.Errorf("Unexpected search results for account ID: %v", roles)
+	}
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"sort"
+	"strings"
+)
+
 // RoleConfig represents a single mapping of an account role to assume.
 type RoleConfig struct {
 	Name             string   `yaml:"name"`
@@ -7,4 +12,137 @@ type RoleConfig struct {
 	IsDefault        bool     `yaml:"default"`
 	Aliases          []string `yaml:"aliases"`
 	TargetAWSProfile string   `yaml:"target_aws_profile"`
+	Description      string   `yaml:"description,omitempty"`
+	// Group is a free-form grouping for the role, e.g. the business unit or product that the account belongs to.
+	Group       string `yaml:"group,omitempty"`
+	AccountName string `yaml:"account_name,omitempty"`
+	// Tags classify the role (e.g. env: prod, team: data), and can be used to select or filter roles.
+	Tags map[string]string `yaml:"tags,omitempty"`
+	// Metadata is arbitrary information about the role that roo passes through to the commands it runs.
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	// Confirm requires the user to answer a yes/no prompt before roo uses the role.
+	Confirm bool `yaml:"confirm,omitempty"`
+	// Protected requires the user to type the account name (See ConfirmationPhrase) before roo uses the role.
+	Protected bool `yaml:"protected,omitempty"`
+	// AllowedCommands and DeniedCommands are patterns for the commands that roo will (or won't) run with the role.
+	// See CheckCommand for the details.
+	AllowedCommands []string `yaml:"allowed_commands,omitempty"`
+	DeniedCommands  []string `yaml:"denied_commands,omitempty"`
+	// AuthSource is the name of the auth source that the role is assumed from. Defaults to the default auth source.
+	AuthSource string `yaml:"auth_source,omitempty"`
+}
+
+// RequiresConfirmation returns true if the user needs to confirm that they meant to use the role.
+func (r *RoleConfig) RequiresConfirmation() bool {
+	return r.Confirm || r.Protected
+}
+
+// ConfirmationPhrase returns what the user has to type to use a protected role - The account name if set, otherwise
+// the role name.
+func (r *RoleConfig) ConfirmationPhrase() string {
+	if r.AccountName != "" {
+		return r.AccountName
+	}
+	return r.Name
+}
+
+// AccountID returns the ID of the account that the role lives in, as parsed from the role ARN.
+// An empty string is returned if the ARN isn't a valid IAM role ARN.
+func (r *RoleConfig) AccountID() string {
+	// arn:aws:iam::000000000000:role/ReadOnly
+	parts := strings.SplitN(r.ARN, ":", 6)
+	if len(parts) != 6 || parts[2] != "iam" || !strings.HasPrefix(parts[5], "role/") {
+		return ""
+	}
+	return parts[4]
+}
+
+// RoleName returns the name of the role (without any path), as parsed from the role ARN.
+// An empty string is returned if the ARN isn't a valid IAM role ARN.
+func (r *RoleConfig) RoleName() string {
+	if r.AccountID() == "" {
+		return ""
+	}
+	return r.ARN[strings.LastIndex(r.ARN, "/")+1:]
+}
+
+// MatchesTags returns true if the role has every one of the given tags. Tag keys are case-insensitive, values are not.
+func (r *RoleConfig) MatchesTags(tags map[string]string) bool {
+	for key, value := range tags {
+		found := false
+		for roleKey, roleValue := range r.Tags {
+			if strings.EqualFold(roleKey, key) && roleValue == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatTags returns the role's tags as a sorted, comma-separated list of key=value pairs.
+func (r *RoleConfig) FormatTags() string {
+	return FormatTags(r.Tags)
+}
+
+// FormatTags returns tags as a sorted, comma-separated list of key=value pairs - The inverse of ParseTagSelector.
+func FormatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// EnvironmentVariables returns the environment variables that describe the role to the commands that roo runs.
+// Tags and metadata are exported as ROO_TAG_<KEY> and ROO_METADATA_<KEY> respectively.
+func (r *RoleConfig) EnvironmentVariables() map[string]string {
+	variables := map[string]string{
+		"ROO_ROLE_NAME":  r.Name,
+		"ROO_ROLE_ARN":   r.ARN,
+		"ROO_ACCOUNT_ID": r.AccountID(),
+	}
+	if r.AccountName != "" {
+		variables["ROO_ACCOUNT_NAME"] = r.AccountName
+	}
+	if r.Group != "" {
+		variables["ROO_ROLE_GROUP"] = r.Group
+	}
+	for key, value := range r.Tags {
+		variables["ROO_TAG_"+environmentVariableKey(key)] = value
+	}
+	for key, value := range r.Metadata {
+		variables["ROO_METADATA_"+environmentVariableKey(key)] = value
+	}
+	return variables
+}
+
+// environmentVariableKey upper-cases key, and replaces anything that isn't valid in an environment variable name
+// with an underscore.
+func environmentVariableKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(key))
+}
+
+// ParseTagSelector parses a tag selector of the form 'key=value,key2=value2'. It returns false if s isn't a tag
+// selector, i.e. if any of the comma-separated parts aren't a key=value pair.
+func ParseTagSelector(s string) (map[string]string, bool) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, false
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, true
 }
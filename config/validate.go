@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mfaSerialPattern matches the ARN of an IAM MFA device, e.g. arn:aws:iam::000000000000:mfa/someone
+var mfaSerialPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(\d{12}):mfa/.+$`)
+
+// Validate checks the config for mistakes that Load doesn't catch, such as roles with invalid ARNs, or names and
+// aliases that are used by more than one role. All of the problems found are returned.
+func (c *Config) Validate() []error {
+	var problems []error
+	if !validMFASerial(c.MFASerial) {
+		problems = append(problems, fmt.Errorf("mfa_serial is not a valid MFA device ARN: %s", c.MFASerial))
+	}
+	if c.MFAAttempts < 0 {
+		problems = append(problems, fmt.Errorf("mfa_attempts can't be negative: %d", c.MFAAttempts))
+	}
+	if c.MFACodeLength < 0 {
+		problems = append(problems, fmt.Errorf("mfa_code_length can't be negative: %d", c.MFACodeLength))
+	}
+
+	// owners maps each (lower-cased) name and alias to the index of the role that first used it.
+	owners := map[string]int{}
+	var defaults []string
+	for i, role := range c.Roles {
+		if role.Name == "" {
+			problems = append(problems, fmt.Errorf("role %d (%s) doesn't have a name", i+1, role.ARN))
+		}
+		if role.AccountID() == "" {
+			problems = append(problems, fmt.Errorf("role '%s' doesn't have a valid IAM role ARN: '%s'", role.Name, role.ARN))
+		}
+		if role.IsDefault {
+			defaults = append(defaults, role.Name)
+		}
+		for _, name := range append([]string{role.Name}, role.Aliases...) {
+			if name == "" {
+				continue
+			}
+			key := strings.ToLower(name)
+			if owner, found := owners[key]; found && owner != i {
+				problems = append(problems, fmt.Errorf(
+					"'%s' is used by both role '%s' and role '%s'", name, c.Roles[owner].Name, role.Name,
+				))
+			} else if !found {
+				owners[key] = i
+			}
+		}
+	}
+	problems = append(problems, c.validateAuthSources()...)
+	if len(defaults) > 1 {
+		problems = append(problems, fmt.Errorf(
+			"more than one role is marked as the default: %s", strings.Join(defaults, ", "),
+		))
+	}
+	return problems
+}
+
+// validMFASerial returns false if serial looks like an ARN, but isn't the ARN of an MFA device. Anything else could be
+// the serial number of a hardware token.
+func validMFASerial(serial string) bool {
+	return !strings.HasPrefix(serial, "arn:") || MFASerialAccountID(serial) != ""
+}
+
+// MFASerialAccountID returns the ID of the account that the MFA device with the given serial ARN belongs to. An empty
+// string is returned if serial isn't the ARN of an MFA device (e.g. it's the serial number of a hardware token).
+func MFASerialAccountID(serial string) string {
+	matches := mfaSerialPattern.FindStringSubmatch(serial)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
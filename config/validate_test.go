@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	if problems := testConfig().Validate(); len(problems) != 0 {
+		t.Errorf("Expected a valid config, got %v", problems)
+	}
+
+	c := testConfig()
+	c.MFASerial = "arn:aws:iam::000000000000:user/someone"
+	c.Roles[0].IsDefault = true
+	c.Roles[1].IsDefault = true
+	c.Roles[1].ARN = "arn:aws:iam::111111111111:user/Developer"
+	c.Roles[2].Aliases = append(c.Roles[2].Aliases, "Something-Dev")
+	c.Roles = append(c.Roles, RoleConfig{Name: "something-test-readonly", ARN: "arn:aws:iam::222222222222:role/ReadOnly"})
+	// The MFA serial, the ARN of the second role, the clashing alias and name, and the two default roles.
+	if problems := c.Validate(); len(problems) != 5 {
+		t.Errorf("Expected 5 problems, got %d: %v", len(problems), problems)
+	}
+}
+
+func TestMFASerialAccountID(t *testing.T) {
+	for serial, expected := range map[string]string{
+		"arn:aws:iam::000000000000:mfa/someone":        "000000000000",
+		"arn:aws-us-gov:iam::111111111111:mfa/someone": "111111111111",
+		"arn:aws:iam::000000000000:user/someone":       "",
+		"GAHT12345678":                                 "",
+	} {
+		if actual := MFASerialAccountID(serial); actual != expected {
+			t.Errorf("MFASerialAccountID(%q) returned %q, expected %q", serial, actual, expected)
+		}
+	}
+}
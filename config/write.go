@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// mfaSerialLine matches the top-level mfa_serial setting in a config file, capturing any comment at the end of it.
+var mfaSerialLine = regexp.MustCompile(`^mfa_serial\s*:[^#]*(#.*)?$`)
+
+// SetMFASerial sets mfa_serial in the config file at filePath to serial. The file is edited line by line rather than
+// re-marshalled, so that everything else in it (including comments) is left as it was. If the file doesn't exist,
+// it's created.
+func SetMFASerial(filePath, serial string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	setting := "mfa_serial: " + serial
+	lines := strings.SplitAfter(string(data), "\n")
+	replaced := false
+	for i, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		if match := mfaSerialLine.FindStringSubmatch(content); match != nil {
+			newLine := setting
+			if match[1] != "" {
+				newLine += " " + match[1]
+			}
+			lines[i] = newLine + line[len(content):]
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		// Add it at the top of the file, but after the document start marker if there is one.
+		position := 0
+		if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+			position = 1
+		}
+		lines = append(lines[:position], append([]string{setting + "\n"}, lines[position:]...)...)
+	}
+	return os.WriteFile(filePath, []byte(strings.Join(lines, "")), mode)
+}
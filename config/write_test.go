@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetMFASerial(t *testing.T) {
+	serial := "arn:aws:iam::000000000000:mfa/someone"
+	for _, test := range []struct {
+		name, before, after string
+	}{
+		{
+			name:   "replace",
+			before: "# My config\nmfa_serial: arn:aws:iam::000000000000:mfa/your_mfa_serial # The MFA device\nroles: []\n",
+			after:  "# My config\nmfa_serial: " + serial + " # The MFA device\nroles: []\n",
+		},
+		{
+			name:   "add",
+			before: "---\nroles:\n  - name: dev # Development\n",
+			after:  "---\nmfa_serial: " + serial + "\nroles:\n  - name: dev # Development\n",
+		},
+		{
+			name:   "ignore nested",
+			before: "auth_sources:\n  - name: corp\n    mfa_serial: something\n",
+			after:  "mfa_serial: " + serial + "\nauth_sources:\n  - name: corp\n    mfa_serial: something\n",
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(test.before), 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetMFASerial(path, serial); err != nil {
+			t.Fatalf("%s: Unable to set the MFA serial: %s", test.name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.after {
+			t.Errorf("%s: Unexpected config file:\n%s", test.name, data)
+		}
+		if conf, err := Load(path); err != nil || conf.MFASerial != serial {
+			t.Errorf("%s: Expected the MFA serial to be loaded, got %v (error: %v)", test.name, conf, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := SetMFASerial(path, serial); err != nil {
+		t.Fatalf("Unable to create a config file: %s", err)
+	}
+	if conf, err := Load(path); err != nil || conf.MFASerial != serial {
+		t.Errorf("Expected the MFA serial to be loaded from a new file, got %v (error: %v)", conf, err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jkueh/roo/config"
+)
+
+// confirmationCountdown is how many seconds roo waits after the user confirms a yes/no prompt before it carries on,
+// so that if muscle memory got the better of them, there's still time to hit Ctrl-C. confirmationTick is the length of
+// each of those seconds - It's only a variable so that tests don't have to wait.
+var (
+	confirmationCountdown = 3
+	confirmationTick      = time.Second
+)
+
+// confirmRoleUsage asks the user (on the terminal) to confirm that they really meant to use a role flagged with
+// 'confirm' or 'protected' to perform action. It returns an error if they didn't confirm, or if there's nobody to ask.
+func confirmRoleUsage(role *config.RoleConfig, action string) error {
+	tty, err := openTerminal()
+	if err != nil {
+		return fmt.Errorf("role '%s' requires confirmation (use -yes to skip it): %w", role.Name, err)
+	}
+	defer tty.Close()
+	return confirmOnTerminal(tty, role, action)
+}
+
+// confirmOnTerminal is confirmRoleUsage, once the terminal has been opened.
+func confirmOnTerminal(tty *terminal, role *config.RoleConfig, action string) error {
+	out := tty.out
+	fmt.Fprintf(out, "WARNING: Role '%s' (%s) is flagged as sensitive.\n", role.Name, role.ARN)
+	if role.Description != "" {
+		fmt.Fprintf(out, "         %s\n", role.Description)
+	}
+	fmt.Fprintf(out, "You're about to %s.\n", action)
+
+	if role.Protected {
+		phrase := role.ConfirmationPhrase()
+		answer, err := tty.readLine(fmt.Sprintf("Type '%s' to continue", phrase))
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(answer) != phrase {
+			return fmt.Errorf("confirmation did not match '%s'", phrase)
+		}
+		return nil
+	}
+
+	answer, err := tty.readLine("Continue? [y/N]")
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("not confirmed")
+	}
+	for remaining := confirmationCountdown; remaining > 0; remaining-- {
+		fmt.Fprintf(out, "\rContinuing in %d... (Ctrl-C to abort)", remaining)
+		time.Sleep(confirmationTick)
+	}
+	fmt.Fprint(out, "\r\x1b[K")
+	return nil
+}
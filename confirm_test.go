@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jkueh/roo/config"
+)
+
+// answeringTerminal returns a terminal that answers prompts with input, along with a function that returns everything
+// written to it so far.
+func answeringTerminal(t *testing.T, input string) (*terminal, func() string) {
+	t.Helper()
+	in, err := os.CreateTemp(t.TempDir(), "tty-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { in.Close() })
+	if _, err := in.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.CreateTemp(t.TempDir(), "tty-out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+	return &terminal{in: in, out: out}, func() string {
+		written, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(written)
+	}
+}
+
+func TestConfirmRoleUsage(t *testing.T) {
+	tick := confirmationTick
+	confirmationTick = 0
+	t.Cleanup(func() { confirmationTick = tick })
+
+	confirmRole := &config.RoleConfig{Name: "prod-admin", ARN: "arn:aws:iam::111111111111:role/Admin", Confirm: true}
+	protectedRole := &config.RoleConfig{
+		Name:        "prod-admin",
+		ARN:         "arn:aws:iam::111111111111:role/Admin",
+		AccountName: "production",
+		Protected:   true,
+	}
+	tests := []struct {
+		role      *config.RoleConfig
+		answer    string
+		confirmed bool
+	}{
+		{confirmRole, "y\n", true},
+		{confirmRole, "YES\n", true},
+		{confirmRole, "n\n", false},
+		{confirmRole, "\n", false},
+		{protectedRole, "production\n", true},
+		{protectedRole, "prod-admin\n", false},
+	}
+	for _, test := range tests {
+		tty, written := answeringTerminal(t, test.answer)
+		err := confirmOnTerminal(tty, test.role, "run 'aws s3 ls'")
+		if test.confirmed && err != nil {
+			t.Errorf("Expected %q to confirm the role, got %s", test.answer, err)
+		} else if !test.confirmed && err == nil {
+			t.Errorf("Expected %q not to confirm the role", test.answer)
+		}
+
+		// The countdown only happens once a yes/no prompt has been answered with yes.
+		output := written()
+		countdown := strings.Index(output, "Continuing in 3...")
+		if test.role.Confirm && test.confirmed {
+			if prompt := strings.Index(output, "Continue? [y/N]"); countdown < prompt {
+				t.Errorf("Expected the countdown to follow the prompt, got %q", output)
+			}
+		} else if countdown >= 0 {
+			t.Errorf("Expected no countdown after %q, got %q", test.answer, output)
+		}
+	}
+}
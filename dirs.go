@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/jkueh/roo/util"
+)
+
+// legacyConfigDirName is the directory (relative to the home directory) that roo used before it followed the XDG base
+// directory specification on Linux.
+const legacyConfigDirName = ".roo"
+
+// setAsideSuffix is added to the name of a legacy config dir that can't be migrated, so that roo stops trying.
+const setAsideSuffix = ".migrated"
+
+// defaultConfigDir returns the directory that roo should read its config from if not otherwise specified.
+// On Linux, this is $XDG_CONFIG_HOME/roo (falling back to ~/.config/roo), and ~/.roo everywhere else.
+func defaultConfigDir(homeDir string) string {
+	if runtime.GOOS != "linux" {
+		return filepath.Join(homeDir, legacyConfigDirName)
+	}
+	return filepath.Join(getEnvOrDefault("XDG_CONFIG_HOME", filepath.Join(homeDir, ".config")), "roo")
+}
+
+// defaultCacheDir returns the directory that roo should cache credentials in if not otherwise specified.
+// On Linux, $XDG_RUNTIME_DIR is preferred as it's a per-user tmpfs that is cleared on logout, falling back to
+// $XDG_CACHE_HOME (or ~/.cache). Everywhere else, a 'cache' directory inside configDir is used.
+func defaultCacheDir(homeDir, configDir string) string {
+	if runtime.GOOS != "linux" {
+		return filepath.Join(configDir, "cache")
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "roo")
+	}
+	return filepath.Join(getEnvOrDefault("XDG_CACHE_HOME", filepath.Join(homeDir, ".cache")), "roo")
+}
+
+// defaultStateDir returns the directory that roo should keep state that isn't worth backing up (e.g. the recently used
+// roles) in. On Linux, this is $XDG_STATE_HOME/roo (falling back to ~/.local/state/roo), and the cache directory inside
+// configDir everywhere else.
+func defaultStateDir(homeDir, configDir string) string {
+	if runtime.GOOS != "linux" {
+		return filepath.Join(configDir, "cache")
+	}
+	return filepath.Join(getEnvOrDefault("XDG_STATE_HOME", filepath.Join(homeDir, ".local", "state")), "roo")
+}
+
+// migrateLegacyConfigDir moves the contents of a pre-XDG ~/.roo directory into newConfigDir, with the exception of the
+// cache directory, the contents of which are moved into newCacheDir. The legacy directory is removed once empty.
+// It's a no-op (returning false) if legacyDir doesn't exist.
+//
+// If newConfigDir already contains a config file, legacyDir is renamed (with setAsideSuffix) rather than migrated, and
+// an error says so - It's only returned once, as legacyDir no longer exists afterwards. If moving a file fails, the
+// files that were already moved are moved back, so that nothing is left half-migrated.
+func migrateLegacyConfigDir(legacyDir, newConfigDir, newCacheDir string) (bool, error) {
+	if filepath.Clean(legacyDir) == filepath.Clean(newConfigDir) {
+		return false, nil
+	}
+	entries, err := os.ReadDir(legacyDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(filepath.Join(newConfigDir, "config.yaml")); err == nil {
+		setAsideDir := legacyDir + setAsideSuffix
+		if err := os.Rename(legacyDir, setAsideDir); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("%s already contains a config file, so %s was renamed to %s instead of being migrated",
+			newConfigDir, legacyDir, setAsideDir)
+	}
+
+	if err := util.EnsureDirExists(newConfigDir, 0700); err != nil {
+		return false, err
+	}
+	if err := util.EnsureDirExists(newCacheDir, 0700); err != nil {
+		return false, err
+	}
+
+	// Work out everything that needs moving first, as {src, dst} pairs.
+	var moves [][2]string
+	legacyCacheDir := ""
+	for _, entry := range entries {
+		src := filepath.Join(legacyDir, entry.Name())
+		if entry.IsDir() && entry.Name() == "cache" {
+			legacyCacheDir = src
+			cacheEntries, err := os.ReadDir(src)
+			if err != nil {
+				return false, err
+			}
+			for _, cacheEntry := range cacheEntries {
+				moves = append(moves, [2]string{
+					filepath.Join(src, cacheEntry.Name()),
+					filepath.Join(newCacheDir, cacheEntry.Name()),
+				})
+			}
+			continue
+		}
+		moves = append(moves, [2]string{src, filepath.Join(newConfigDir, entry.Name())})
+	}
+
+	for i, move := range moves {
+		if err := moveFile(move[0], move[1]); err != nil {
+			return false, undoMoves(moves[:i], err)
+		}
+	}
+	if legacyCacheDir != "" {
+		if err := os.Remove(legacyCacheDir); err != nil {
+			return true, err
+		}
+	}
+	return true, os.Remove(legacyDir)
+}
+
+// undoMoves moves the files in moves (as {src, dst} pairs) back to where they came from, after migrating failed with
+// cause. cause is returned, along with the first file that couldn't be moved back (if any).
+func undoMoves(moves [][2]string, cause error) error {
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := moveFile(moves[i][1], moves[i][0]); err != nil {
+			return fmt.Errorf("%w (and unable to move %s back to %s: %s)", cause, moves[i][1], moves[i][0], err)
+		}
+	}
+	return cause
+}
+
+// moveFile renames src to dst, falling back to a copy and delete if they're on different filesystems (e.g. when
+// moving into a tmpfs-backed $XDG_RUNTIME_DIR). It won't replace dst if it already exists, and removes any partial
+// copy if copying fails.
+func moveFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("unable to move %s to %s, as it already exists", src, dst)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("unable to move directory %s to %s", src, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
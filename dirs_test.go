@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDefaultCacheDirPrefersRuntimeDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG base directories are only used on Linux")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	t.Setenv("XDG_CACHE_HOME", "/home/someone/.cache")
+	if dir := defaultCacheDir("/home/someone", "/home/someone/.config/roo"); dir != "/run/user/1000/roo" {
+		t.Errorf("Expected the cache dir to be in XDG_RUNTIME_DIR, got %s", dir)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if dir := defaultCacheDir("/home/someone", "/home/someone/.config/roo"); dir != "/home/someone/.cache/roo" {
+		t.Errorf("Expected the cache dir to be in XDG_CACHE_HOME, got %s", dir)
+	}
+}
+
+func TestMigrateLegacyConfigDir(t *testing.T) {
+	tempDir := t.TempDir()
+	legacyDir := filepath.Join(tempDir, ".roo")
+	newConfigDir := filepath.Join(tempDir, ".config", "roo")
+	newCacheDir := filepath.Join(tempDir, "run", "roo")
+
+	if err := os.MkdirAll(filepath.Join(legacyDir, "cache"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyDir, "config.yaml"), []byte("roles: []\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyDir, "cache", "000000000000-ReadOnly.gob"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := migrateLegacyConfigDir(legacyDir, newConfigDir, newCacheDir)
+	if err != nil {
+		t.Fatalf("Migration returned an error: %s", err)
+	} else if !migrated {
+		t.Fatalf("Migration did not report that it migrated anything")
+	}
+
+	if _, err := os.Stat(filepath.Join(newConfigDir, "config.yaml")); err != nil {
+		t.Errorf("Config file was not migrated: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(newCacheDir, "000000000000-ReadOnly.gob")); err != nil {
+		t.Errorf("Cache file was not migrated: %s", err)
+	}
+	if _, err := os.Stat(legacyDir); !os.IsNotExist(err) {
+		t.Errorf("Legacy config dir was not removed")
+	}
+
+	// A second run should be a no-op.
+	if migrated, err := migrateLegacyConfigDir(legacyDir, newConfigDir, newCacheDir); err != nil || migrated {
+		t.Errorf("Second migration was not a no-op: %t, %s", migrated, err)
+	}
+}
+
+func TestMigrateLegacyConfigDirSetsAsideWhenBothExist(t *testing.T) {
+	tempDir := t.TempDir()
+	legacyDir := filepath.Join(tempDir, ".roo")
+	newConfigDir := filepath.Join(tempDir, ".config", "roo")
+	for _, dir := range []string{legacyDir, newConfigDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("roles: []\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if migrated, err := migrateLegacyConfigDir(legacyDir, newConfigDir, filepath.Join(tempDir, "cache")); err == nil ||
+		migrated {
+		t.Errorf("Expected an error saying the legacy config dir was set aside, got %t, %v", migrated, err)
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir+setAsideSuffix, "config.yaml")); err != nil {
+		t.Errorf("Legacy config dir was not set aside: %s", err)
+	}
+	// So it's only reported once.
+	if migrated, err := migrateLegacyConfigDir(legacyDir, newConfigDir, filepath.Join(tempDir, "cache")); err != nil ||
+		migrated {
+		t.Errorf("Second migration was not a no-op: %t, %s", migrated, err)
+	}
+}
+
+func TestMigrateLegacyConfigDirUndoesFailedMigration(t *testing.T) {
+	tempDir := t.TempDir()
+	legacyDir := filepath.Join(tempDir, ".roo")
+	newConfigDir := filepath.Join(tempDir, ".config", "roo")
+	newCacheDir := filepath.Join(tempDir, "run", "roo")
+	const cacheFile = "000000000000-ReadOnly.gob"
+	files := map[string]string{
+		filepath.Join(legacyDir, "cache", cacheFile): filepath.Join(newCacheDir, cacheFile),
+		filepath.Join(legacyDir, "config.yaml"):      filepath.Join(newConfigDir, "config.yaml"),
+	}
+	for src := range files {
+		if err := os.MkdirAll(filepath.Dir(src), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// This file can't be moved, as there's already one with the same name in the new config dir.
+	for _, dir := range []string{legacyDir, newConfigDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "used_codes.json"), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if migrated, err := migrateLegacyConfigDir(legacyDir, newConfigDir, newCacheDir); err == nil || migrated {
+		t.Errorf("Expected the migration to fail, got %t, %v", migrated, err)
+	}
+	for src, dst := range files {
+		if _, err := os.Stat(src); err != nil {
+			t.Errorf("File was not moved back: %s", err)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("File was left behind in %s", dst)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+// maxClockSkew is how far the local clock can drift from AWS before requests are rejected outright, as their
+// signatures are considered to have expired.
+const maxClockSkew = 5 * time.Minute
+
+// browserCommands are the commands that github.com/pkg/browser uses to open the console (with -console), by OS. It
+// uses the first one that's found. Windows (which uses ShellExecute) isn't listed, as it doesn't need a command.
+var browserCommands = map[string][]string{
+	"linux":   {"xdg-open", "x-www-browser", "www-browser"},
+	"freebsd": {"xdg-open"},
+	"netbsd":  {"xdg-open"},
+	"openbsd": {"xdg-open"},
+	"darwin":  {"open"},
+}
+
+// checkStatus is the outcome of one of the checks run by 'roo doctor'.
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	// checkSkip is used when a check depends on one that failed.
+	checkSkip
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkPass:
+		return "PASS"
+	case checkSkip:
+		return "SKIP"
+	case checkWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// checkResult is the result of one of the checks run by 'roo doctor'.
+type checkResult struct {
+	Name    string
+	Status  checkStatus
+	Message string
+	// Remediation tells the user how to fix a warning or failure.
+	Remediation string
+}
+
+// runDoctor implements 'roo doctor', which checks that roo is set up correctly. It exits with 1 if any of the checks
+// fail - Warnings are for things that only affect some features, or that roo will sort out itself.
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	addCommonFlags(flags)
+	var baseProfile, mfaSerial string
+	flags.StringVar(
+		&baseProfile,
+		"profile",
+		os.Getenv(envProfile),
+		"The base AWS config profile to check. (env: "+envProfile+")",
+	)
+	flags.StringVar(
+		&mfaSerial,
+		"mfa-serial",
+		os.Getenv(envMFASerial),
+		"The serial ARN of the MFA device to check. (env: "+envMFASerial+")",
+	)
+	parseCommonFlags(flags, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var results []checkResult
+	conf, result := checkConfigFile(configFile)
+	results = append(results, result)
+	if conf == nil {
+		// Carry on with the defaults, as most of the other checks are still useful.
+		conf = &config.Config{}
+	}
+	results = append(results,
+		checkDir("Config directory", filepath.Dir(configFile)),
+		checkDir("Cache directory", cacheDir),
+		checkCache(conf.CacheBackend, cacheDir),
+	)
+
+	client := roo.New(conf, nil, nil)
+	client.OpenKeyStore = openKeyStore
+	applyNetworkConfig(client, conf)
+	client.Profile = baseProfile
+	results = append(results, checkClockSkew(ctx, client))
+
+	client.MFASerial = mfaSerial
+	for _, source := range doctorAuthSources(conf) {
+		results = append(results, checkAuthSource(ctx, client, source)...)
+	}
+
+	results = append(results, checkBrowser(runtime.GOOS))
+
+	writeCheckResults(os.Stdout, results)
+	return doctorExitCode(results)
+}
+
+// checkConfigFile loads and validates the config file. The config is returned if it could be loaded, even if it
+// isn't valid.
+func checkConfigFile(path string) (*config.Config, checkResult) {
+	result := checkResult{Name: "Config file"}
+	conf, err := config.Load(path)
+	if os.IsNotExist(err) {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("%s doesn't exist", path)
+		result.Remediation = "Run roo once to create an example config file, then add your roles to it - Or use " +
+			"-config (or " + envConfigFile + ") if it's somewhere else."
+		return nil, result
+	} else if err != nil {
+		result.Status = checkFail
+		result.Message = err.Error()
+		result.Remediation = "Fix the YAML syntax error - See example.config.yaml for the expected format."
+		return nil, result
+	}
+
+	if problems := conf.Validate(); len(problems) > 0 {
+		lines := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			lines = append(lines, "- "+problem.Error())
+		}
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("%s has problems:\n%s", path, strings.Join(lines, "\n"))
+		result.Remediation = "Fix the problems listed above in the config file."
+		return conf, result
+	}
+	if len(conf.Roles) == 0 {
+		result.Status = checkWarn
+		result.Message = fmt.Sprintf("%s doesn't have any roles", path)
+		result.Remediation = "Add the roles that you want to assume to the config file."
+		return conf, result
+	}
+	result.Message = fmt.Sprintf("Loaded %d roles from %s", len(conf.Roles), path)
+	return conf, result
+}
+
+// checkDir checks that dir exists, that other users can't read it, and that we can write to it.
+func checkDir(name, dir string) checkResult {
+	result := checkResult{Name: name}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		result.Status = checkWarn
+		result.Message = fmt.Sprintf("%s doesn't exist", dir)
+		result.Remediation = "It will be created the next time roo runs."
+		return result
+	} else if err != nil {
+		result.Status = checkFail
+		result.Message = err.Error()
+		result.Remediation = "Make sure that you have access to " + dir + "."
+		return result
+	}
+	if !info.IsDir() {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("%s isn't a directory", dir)
+		result.Remediation = "Move " + dir + " out of the way, so that roo can create the directory."
+		return result
+	}
+
+	probe, err := os.CreateTemp(dir, ".roo-doctor-*")
+	if err != nil {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("Unable to write to %s: %s", dir, err)
+		result.Remediation = "Make sure that you own " + dir + ", e.g. with chown."
+		return result
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	// Windows doesn't have Unix permissions, and Go doesn't report its ACLs.
+	if mode := info.Mode().Perm(); runtime.GOOS != "windows" && mode&0077 != 0 {
+		result.Status = checkWarn
+		result.Message = fmt.Sprintf("%s can be accessed by other users (mode %04o)", dir, mode)
+		result.Remediation = "Run: chmod 700 " + dir
+		return result
+	}
+	result.Message = dir
+	return result
+}
+
+// checkCache checks that every entry in the credential cache can be read.
+func checkCache(backend, dir string) checkResult {
+	result := checkResult{Name: "Credential cache"}
+	store, err := cachedcredsprovider.NewStore(backend, dir)
+	if err != nil {
+		result.Status = checkFail
+		result.Message = err.Error()
+		result.Remediation = "Set cache_backend in the config file to one that's available on this system, " +
+			"e.g. file."
+		return result
+	}
+	keys, err := store.List()
+	if err != nil {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("Unable to list cached credentials: %s", err)
+		result.Remediation = "Check that the " + storeBackendName(backend) + " cache backend is working."
+		return result
+	}
+
+	var unreadable []string
+	var roles, expired, sessions int
+	for _, key := range keys {
+		creds, err := store.Get(key)
+		if err != nil {
+			unreadable = append(unreadable, fmt.Sprintf("- %s: %s", key, err))
+		} else if roo.IsSessionCacheKey(key) {
+			sessions++
+		} else {
+			roles++
+			if creds != nil && creds.IsExpired() {
+				expired++
+			}
+		}
+	}
+	if len(unreadable) > 0 {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("Unable to read %d of %d cached credentials:\n%s",
+			len(unreadable), len(keys), strings.Join(unreadable, "\n"))
+		result.Remediation = "Run roo with -refresh for the affected roles to replace them."
+		return result
+	}
+	result.Message = fmt.Sprintf("%d cached credentials (%d expired) and %d auth source sessions in the %s cache",
+		roles, expired, sessions, storeBackendName(backend))
+	return result
+}
+
+// storeBackendName returns the name of the cache backend, as set by cache_backend.
+func storeBackendName(backend string) string {
+	if backend == "" {
+		return cachedcredsprovider.BackendFile
+	}
+	return backend
+}
+
+// checkClockSkew checks that the local clock is close enough to AWS for MFA codes to be accepted.
+func checkClockSkew(ctx context.Context, client *roo.Client) checkResult {
+	result := checkResult{Name: "Clock"}
+	skew, err := client.ClockSkew(ctx)
+	if err != nil {
+		result.Status = checkWarn
+		result.Message = err.Error()
+		result.Remediation = "Check your network connection, or set network.time_source in the config file."
+		return result
+	}
+	threshold := client.ClockSkewThreshold
+	if threshold == 0 {
+		threshold = roo.DefaultClockSkewThreshold
+	}
+	result.Message = roo.DescribeClockSkew(skew)
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case skew > maxClockSkew:
+		result.Status = checkFail
+	case skew > threshold:
+		result.Status = checkWarn
+	default:
+		return result
+	}
+	result.Remediation = "Sync your clock, e.g. by enabling NTP with: timedatectl set-ntp true"
+	return result
+}
+
+// doctorAuthSources returns the auth sources to check - Including the one built from default_profile and mfa_serial,
+// unless another auth source is the default.
+func doctorAuthSources(conf *config.Config) []*config.AuthSource {
+	defaultSource, _ := conf.GetAuthSource(nil)
+	var sources []*config.AuthSource
+	if defaultSource.Name == "" {
+		sources = append(sources, defaultSource)
+	}
+	for i := range conf.AuthSources {
+		sources = append(sources, &conf.AuthSources[i])
+	}
+	return sources
+}
+
+// checkAuthSource checks that the AWS SDK can find credentials for source's profile, and that its MFA devices belong
+// to the same account as those credentials - Otherwise, AWS will reject every code.
+func checkAuthSource(ctx context.Context, client *roo.Client, source *config.AuthSource) []checkResult {
+	suffix := ""
+	if source.Name != "" {
+		suffix = " (" + source.Name + ")"
+	}
+	if source.IsWebIdentity() {
+		return []checkResult{checkWebIdentitySource(ctx, client, source, suffix)}
+	}
+	profile := client.Profile
+	if profile == "" {
+		profile = source.Profile
+	}
+	description := "the default AWS credentials"
+	if profile != "" {
+		description = "AWS profile '" + profile + "'"
+	}
+
+	credentialsResult := checkResult{Name: "Base credentials" + suffix}
+	creds, err := client.BaseCredentials(ctx, source.Name)
+	if err != nil {
+		credentialsResult.Status = checkFail
+		credentialsResult.Message = fmt.Sprintf("Unable to resolve %s: %s", description, err)
+		credentialsResult.Remediation = "Set up credentials for the account that you assume roles from with " +
+			"'aws configure', and set its profile in the config file (or use -profile) if it isn't the default."
+		return []checkResult{credentialsResult, {
+			Name:    "MFA device" + suffix,
+			Status:  checkSkip,
+			Message: "The base credentials are needed to check which account the MFA device belongs to",
+		}}
+	}
+	credentialsResult.Message = fmt.Sprintf("Found %s (%s, from %s)", description, creds.AccessKeyID, creds.Source)
+	results := []checkResult{credentialsResult}
+
+	devices := source.MFASerials
+	if client.MFASerial != "" {
+		devices = []string{client.MFASerial}
+	}
+	if len(devices) == 0 {
+		return append(results, checkResult{
+			Name:        "MFA device" + suffix,
+			Status:      checkWarn,
+			Message:     "No MFA device is configured, so roles will be assumed without MFA",
+			Remediation: "Set mfa_serial in the config file to the ARN of your MFA device (or use -mfa-serial).",
+		})
+	}
+
+	provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return creds, nil
+	})
+	identity, err := client.CallerIdentity(ctx, provider)
+	if err != nil {
+		return append(results, checkResult{
+			Name:        "MFA device" + suffix,
+			Status:      checkFail,
+			Message:     fmt.Sprintf("Unable to check who the base credentials belong to: %s", err),
+			Remediation: "Check that the access key for the base profile is active, and hasn't been rotated.",
+		})
+	}
+	for _, serial := range devices {
+		result := compareMFASerial(serial, aws.ToString(identity.Account), aws.ToString(identity.Arn))
+		result.Name += suffix
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkWebIdentitySource checks that source's web identity token can be exchanged for credentials for its role. It
+// doesn't use MFA, so there's nothing else to check.
+func checkWebIdentitySource(
+	ctx context.Context,
+	client *roo.Client,
+	source *config.AuthSource,
+	suffix string,
+) checkResult {
+	result := checkResult{Name: "Web identity" + suffix}
+	creds, err := client.BaseCredentials(ctx, source.Name)
+	if err != nil {
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("Unable to exchange the web identity token for credentials: %s", err)
+		result.Remediation = "Check that the token file (or command) gives a current token, and that the role's trust " +
+			"policy allows the token's issuer, audience and subject."
+		return result
+	}
+	result.Message = fmt.Sprintf("Exchanged the web identity token for credentials (%s)", creds.AccessKeyID)
+	return result
+}
+
+// compareMFASerial checks that the MFA device with the given serial belongs to the account of the caller.
+func compareMFASerial(serial, callerAccount, callerARN string) checkResult {
+	result := checkResult{Name: "MFA device"}
+	serialAccount := config.MFASerialAccountID(serial)
+	switch {
+	case serialAccount == "":
+		result.Status = checkWarn
+		result.Message = fmt.Sprintf("Unable to tell which account %s belongs to", serial)
+		result.Remediation = "Unless it's a hardware token for the root user, set mfa_serial to the ARN of the " +
+			"device, e.g. arn:aws:iam::" + callerAccount + ":mfa/name"
+	case serialAccount != callerAccount:
+		result.Status = checkFail
+		result.Message = fmt.Sprintf("%s belongs to account %s, but the base credentials are for %s",
+			serial, serialAccount, callerARN)
+		result.Remediation = "Set mfa_serial to the ARN of an MFA device for " + callerARN + ", or use the base " +
+			"profile for the account that the MFA device is in."
+	default:
+		result.Message = fmt.Sprintf("%s is in the same account as %s", serial, callerARN)
+	}
+	return result
+}
+
+// checkBrowser checks that there's a way to open a browser for -console on goos.
+func checkBrowser(goos string) checkResult {
+	result := checkResult{Name: "Browser"}
+	commands, ok := browserCommands[goos]
+	if !ok {
+		if goos != "windows" {
+			result.Status = checkWarn
+			result.Message = "roo can't open a browser on " + goos + ", so -console won't work"
+			result.Remediation = "Use -console-url to print the console URL instead."
+			return result
+		}
+		result.Message = "Using the default browser"
+		return result
+	}
+	for _, command := range commands {
+		if path, err := exec.LookPath(command); err == nil {
+			result.Message = path
+			if goos != "darwin" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+				result.Status = checkWarn
+				result.Message += " (no graphical session was found)"
+				result.Remediation = "If -console doesn't open a browser, use -console-url to print the console URL."
+			}
+			return result
+		}
+	}
+	result.Status = checkWarn
+	result.Message = fmt.Sprintf("None of %s are in your PATH, so -console won't work", strings.Join(commands, ", "))
+	result.Remediation = "Install xdg-utils (or use -console-url to print the console URL instead)."
+	return result
+}
+
+// writeCheckResults writes the results of 'roo doctor' to w, followed by a summary.
+func writeCheckResults(w io.Writer, results []checkResult) {
+	counts := map[checkStatus]int{}
+	for _, result := range results {
+		counts[result.Status]++
+		message := strings.ReplaceAll(result.Message, "\n", "\n       ")
+		fmt.Fprintf(w, "[%s] %s: %s\n", result.Status, result.Name, message)
+		if result.Remediation != "" && (result.Status == checkWarn || result.Status == checkFail) {
+			fmt.Fprintf(w, "       Fix: %s\n", result.Remediation)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+}
+
+// doctorExitCode returns the exit code for results - 1 if any of the checks failed, otherwise 0.
+func doctorExitCode(results []checkResult) int {
+	for _, result := range results {
+		if result.Status == checkFail {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if _, result := checkConfigFile(path); result.Status != checkFail {
+		t.Errorf("Expected a missing config file to fail, got %s", result.Status)
+	}
+
+	if err := os.WriteFile(path, []byte("roles: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if conf, result := checkConfigFile(path); conf != nil || result.Status != checkFail {
+		t.Errorf("Expected an unparseable config file to fail, got %s", result.Status)
+	}
+
+	invalid := "roles:\n- name: dev\n  arn: arn:aws:iam::000000000000:user/dev\n"
+	if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if conf, result := checkConfigFile(path); conf == nil || result.Status != checkFail {
+		t.Errorf("Expected an invalid config file to be loaded, but fail: %s", result.Status)
+	} else if !strings.Contains(result.Message, "valid IAM role ARN") {
+		t.Errorf("Expected the problem to be reported, got: %s", result.Message)
+	}
+
+	valid := "roles:\n- name: dev\n  arn: arn:aws:iam::000000000000:role/dev\n"
+	if err := os.WriteFile(path, []byte(valid), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, result := checkConfigFile(path); result.Status != checkPass {
+		t.Errorf("Expected a valid config file to pass, got %s: %s", result.Status, result.Message)
+	}
+}
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if result := checkDir("Cache directory", dir); result.Status != checkPass {
+		t.Errorf("Expected a private directory to pass, got %s: %s", result.Status, result.Message)
+	}
+	if result := checkDir("Cache directory", filepath.Join(dir, "missing")); result.Status != checkWarn {
+		t.Errorf("Expected a missing directory to warn, got %s", result.Status)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if result := checkDir("Cache directory", file); result.Status != checkFail {
+		t.Errorf("Expected a file to fail, got %s", result.Status)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if result := checkDir("Cache directory", dir); result.Status != checkWarn || result.Remediation == "" {
+		t.Errorf("Expected a directory that others can read to warn, got %s", result.Status)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	dir := t.TempDir()
+	if result := checkCache("", dir); result.Status != checkPass {
+		t.Errorf("Expected an empty cache to pass, got %s: %s", result.Status, result.Message)
+	}
+	store := cachedcredsprovider.NewFileStore(dir)
+	valid := &cachedcredsprovider.CachedCredentials{ExpiresAt: time.Now().Add(time.Hour)}
+	if err := store.Put("111111111111-Developer", valid); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("session_corporate", valid); err != nil {
+		t.Fatal(err)
+	}
+	result := checkCache("", dir)
+	if result.Status != checkPass || !strings.HasPrefix(result.Message, "1 cached credentials (0 expired) and 1 auth") {
+		t.Errorf("Expected sessions to be counted separately, got %s: %s", result.Status, result.Message)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "000000000000-ReadOnly.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if result := checkCache("file", dir); result.Status != checkFail {
+		t.Errorf("Expected a corrupt cache file to fail, got %s", result.Status)
+	}
+	if result := checkCache("carrier-pigeon", dir); result.Status != checkFail {
+		t.Errorf("Expected an unknown cache backend to fail, got %s", result.Status)
+	}
+}
+
+func TestCompareMFASerial(t *testing.T) {
+	callerARN := "arn:aws:iam::000000000000:user/someone"
+	for serial, expected := range map[string]checkStatus{
+		"arn:aws:iam::000000000000:mfa/someone": checkPass,
+		"arn:aws:iam::111111111111:mfa/someone": checkFail,
+		"GAHT12345678":                          checkWarn,
+	} {
+		if result := compareMFASerial(serial, "000000000000", callerARN); result.Status != expected {
+			t.Errorf("Expected %s for %s, got %s: %s", expected, serial, result.Status, result.Message)
+		}
+	}
+}
+
+func TestWriteCheckResults(t *testing.T) {
+	results := []checkResult{
+		{Name: "Clock", Status: checkPass, Message: "local clock is in sync with AWS"},
+		{Name: "AWS CLI", Status: checkWarn, Message: "not found", Remediation: "Install it."},
+		{Name: "Config file", Status: checkFail, Message: "problems:\n- one", Remediation: "Fix them."},
+	}
+	var out bytes.Buffer
+	writeCheckResults(&out, results)
+	expected := "[PASS] Clock: local clock is in sync with AWS\n" +
+		"[WARN] AWS CLI: not found\n" +
+		"       Fix: Install it.\n" +
+		"[FAIL] Config file: problems:\n" +
+		"       - one\n" +
+		"       Fix: Fix them.\n" +
+		"\n1 passed, 1 warnings, 1 failed, 0 skipped\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	if code := doctorExitCode(results); code != 1 {
+		t.Errorf("Expected exit code 1 when a check fails, got %d", code)
+	}
+	if code := doctorExitCode(results[:2]); code != 0 {
+		t.Errorf("Expected exit code 0 with only warnings, got %d", code)
+	}
+}
@@ -3,10 +3,25 @@ package main
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
+)
 
-	"github.com/jkueh/roo/util"
+// Environment variables that can be used in place of command line flags.
+// Precedence is: flag > environment variable > config file > default.
+const (
+	envConfigFile = "ROO_CONFIG"
+	envCacheDir   = "ROO_CACHE_DIR"
+	envRole       = "ROO_ROLE"
+	envProfile    = "ROO_PROFILE"
+	envMFASerial  = "ROO_MFA_SERIAL"
+	envAuthSource = "ROO_AUTH_SOURCE"
+	envTimeout    = "ROO_TIMEOUT"
+	// envKeyPassphrase is the passphrase for the encrypted-file key backend. There's no flag for it, as command lines
+	// aren't secret.
+	envKeyPassphrase = "ROO_KEY_PASSPHRASE"
 )
 
 func init() {
@@ -14,16 +29,6 @@ func init() {
 	debug = strings.ToLower(os.Getenv("DEBUG")) == "true"
 	verbose = strings.ToLower(os.Getenv("VERBOSE")) == "true"
 
-	if debug {
-		log.Println("Debug mode enabled.")
-	}
-	if verbose {
-		log.Println("Verbose mode enabled.")
-	}
-
-	var homeDir string
-	var err error
-
 	if homeDir == "" {
 		if runtime.GOOS == "windows" {
 			homeDir = os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
@@ -38,23 +43,29 @@ func init() {
 		}
 	}
 
+	legacyConfigDir = filepath.Join(homeDir, legacyConfigDirName)
+
 	if configDir == "" {
-		configDir = strings.Join([]string{homeDir, ".roo"}, string(os.PathSeparator))
-	}
-	err = util.EnsureDirExists(configDir, 0700)
-	if err != nil {
-		log.Fatalln("Unable to create configDir:", err)
+		configDir = defaultConfigDir(homeDir)
 	}
 	// Set the default config file path.
 	if configFile == "" {
-		configFile = strings.Join([]string{configDir, "config.yaml"}, string(os.PathSeparator))
+		configFile = filepath.Join(configDir, "config.yaml")
 	}
-
 	if cacheDir == "" {
-		cacheDir = strings.Join([]string{configDir, "cache"}, string(os.PathSeparator))
+		cacheDir = defaultCacheDir(homeDir, configDir)
+	}
+	if stateDir == "" {
+		stateDir = defaultStateDir(homeDir, configDir)
 	}
-	err = util.EnsureDirExists(cacheDir, 0700)
-	if err != nil {
-		log.Fatalln("Unable to create cacheDir:", err)
+
+	// The environment overrides the defaults above - The flags defined in main() then override these.
+	configFile = getEnvOrDefault(envConfigFile, configFile)
+	cacheDir = getEnvOrDefault(envCacheDir, cacheDir)
+	if value := os.Getenv(envTimeout); value != "" {
+		var err error
+		if requestTimeout, err = time.ParseDuration(value); err != nil {
+			log.Fatalln("Invalid "+envTimeout+":", err)
+		}
 	}
 }
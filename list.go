@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+// Cache statuses reported by 'roo list'.
+const (
+	cacheStatusNone    = "none"
+	cacheStatusValid   = "valid"
+	cacheStatusExpired = "expired"
+)
+
+// roleListEntry is a single role, as output by 'roo list'.
+type roleListEntry struct {
+	Name             string            `json:"name" yaml:"name"`
+	ARN              string            `json:"arn" yaml:"arn"`
+	AccountID        string            `json:"account_id" yaml:"account_id"`
+	RoleName         string            `json:"role_name" yaml:"role_name"`
+	Aliases          []string          `json:"aliases" yaml:"aliases"`
+	IsDefault        bool              `json:"default" yaml:"default"`
+	TargetAWSProfile string            `json:"target_aws_profile,omitempty" yaml:"target_aws_profile,omitempty"`
+	Description      string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Group            string            `json:"group,omitempty" yaml:"group,omitempty"`
+	AccountName      string            `json:"account_name,omitempty" yaml:"account_name,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	CacheStatus      string            `json:"cache_status" yaml:"cache_status"`
+	ExpiresAt        *time.Time        `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	// TimeRemaining is the number of whole seconds until the cached credentials expire.
+	TimeRemaining int64 `json:"time_remaining_seconds,omitempty" yaml:"time_remaining_seconds,omitempty"`
+}
+
+// runList implements 'roo list'.
+func runList(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	addCommonFlags(flags)
+	var output, tagSelector string
+	var filter roleListFilter
+	flags.StringVar(&output, "o", "table", "Output format: table, json, yaml or csv.")
+	flags.StringVar(&filter.account, "account", "", "Only list roles in this account ID.")
+	flags.StringVar(&filter.alias, "alias", "", "Only list roles with this alias (case-insensitive).")
+	flags.StringVar(&filter.name, "name", "", "Only list roles with a name containing this value (case-insensitive).")
+	flags.StringVar(&filter.group, "group", "", "Only list roles in this group (case-insensitive).")
+	flags.StringVar(&tagSelector, "tag", "", "Only list roles with all of these tags, e.g. env=prod,team=data")
+	parseCommonFlags(flags, args)
+
+	if tagSelector != "" {
+		var ok bool
+		if filter.tags, ok = config.ParseTagSelector(tagSelector); !ok {
+			fmt.Fprintln(os.Stderr, "Invalid tag selector (expected key=value,key2=value2):", tagSelector)
+			return 2
+		}
+	}
+	if !isRoleListFormat(output) {
+		fmt.Fprintln(os.Stderr, "Unknown output format:", output)
+		flags.Usage()
+		return 2
+	}
+
+	conf := loadConfig()
+	cache := openCredentialStore(conf)
+
+	var entries []roleListEntry
+	for _, role := range conf.Roles {
+		if filter.matches(role) {
+			entries = append(entries, newRoleListEntry(role, cache))
+		}
+	}
+	if err := writeRoleList(os.Stdout, output, entries); err != nil {
+		fmt.Fprintln(os.Stderr, "An error occurred while trying to write the role list:", err)
+		return 1
+	}
+	return 0
+}
+
+// roleListFilter is the set of filters given to 'roo list'. Empty fields match every role.
+type roleListFilter struct {
+	account string
+	alias   string
+	name    string
+	group   string
+	tags    map[string]string
+}
+
+// matches returns true if role passes every filter.
+func (f roleListFilter) matches(role config.RoleConfig) bool {
+	if f.account != "" && role.AccountID() != f.account {
+		return false
+	}
+	if f.alias != "" && !hasAlias(role, f.alias) {
+		return false
+	}
+	if f.name != "" && !strings.Contains(strings.ToLower(role.Name), strings.ToLower(f.name)) {
+		return false
+	}
+	if f.group != "" && !strings.EqualFold(role.Group, f.group) {
+		return false
+	}
+	return role.MatchesTags(f.tags)
+}
+
+// isRoleListFormat returns true if format is one of the output formats that 'roo list' supports.
+func isRoleListFormat(format string) bool {
+	switch format {
+	case "table", "json", "yaml", "csv":
+		return true
+	}
+	return false
+}
+
+// writeRoleList writes entries to w in format (See isRoleListFormat).
+func writeRoleList(w io.Writer, format string, entries []roleListEntry) error {
+	switch format {
+	case "table":
+		return writeRoleTable(w, entries)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []roleListEntry{}
+		}
+		return encoder.Encode(entries)
+	case "yaml":
+		out, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case "csv":
+		return writeRoleCSV(w, entries)
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+func hasAlias(role config.RoleConfig, alias string) bool {
+	for _, roleAlias := range role.Aliases {
+		if strings.EqualFold(roleAlias, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRoleListEntry builds the list entry for role, including the status of any cached credentials.
+func newRoleListEntry(role config.RoleConfig, cache roo.Cache) roleListEntry {
+	entry := roleListEntry{
+		Name:             role.Name,
+		ARN:              role.ARN,
+		AccountID:        role.AccountID(),
+		RoleName:         role.RoleName(),
+		Aliases:          role.Aliases,
+		IsDefault:        role.IsDefault,
+		TargetAWSProfile: role.TargetAWSProfile,
+		Description:      role.Description,
+		Group:            role.Group,
+		AccountName:      role.AccountName,
+		Tags:             role.Tags,
+		Metadata:         role.Metadata,
+		CacheStatus:      cacheStatusNone,
+	}
+	if entry.Aliases == nil {
+		entry.Aliases = []string{}
+	}
+
+	cacheKey, err := roo.CacheKey(&role)
+	if err != nil {
+		return entry
+	}
+	cached, err := cache.Get(cacheKey)
+	if err != nil || cached == nil {
+		return entry
+	}
+
+	expiresAt := cached.ExpiresAt
+	entry.ExpiresAt = &expiresAt
+	if cached.IsExpired() {
+		entry.CacheStatus = cacheStatusExpired
+	} else {
+		entry.CacheStatus = cacheStatusValid
+		entry.TimeRemaining = int64(time.Until(expiresAt).Seconds())
+	}
+	return entry
+}
+
+func writeRoleTable(w io.Writer, entries []roleListEntry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "No roles found.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tGROUP\tACCOUNT\tROLE\tALIASES\tTAGS\tCACHE\tEXPIRES IN")
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.IsDefault {
+			name += " (default)"
+		}
+		expiresIn := "-"
+		if entry.CacheStatus == cacheStatusValid {
+			expiresIn = (time.Duration(entry.TimeRemaining) * time.Second).String()
+		}
+		account := entry.AccountID
+		if entry.AccountName != "" {
+			account += " (" + entry.AccountName + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, entry.Group, account, entry.RoleName,
+			strings.Join(entry.Aliases, ","), config.FormatTags(entry.Tags), entry.CacheStatus, expiresIn)
+	}
+	return tw.Flush()
+}
+
+func writeRoleCSV(w io.Writer, entries []roleListEntry) error {
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write([]string{
+		"name", "arn", "account_id", "account_name", "role_name", "group", "description", "aliases", "tags",
+		"default", "target_aws_profile", "cache_status", "expires_at", "time_remaining_seconds",
+	})
+	for _, entry := range entries {
+		expiresAt := ""
+		if entry.ExpiresAt != nil {
+			expiresAt = entry.ExpiresAt.Format(time.RFC3339)
+		}
+		csvWriter.Write([]string{
+			entry.Name, entry.ARN, entry.AccountID, entry.AccountName, entry.RoleName, entry.Group, entry.Description,
+			strings.Join(entry.Aliases, ";"), strings.ReplaceAll(config.FormatTags(entry.Tags), ",", ";"),
+			fmt.Sprint(entry.IsDefault), entry.TargetAWSProfile, entry.CacheStatus, expiresAt,
+			fmt.Sprint(entry.TimeRemaining),
+		})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+func testListRoles() []config.RoleConfig {
+	return []config.RoleConfig{
+		{
+			Name:        "prod-readonly",
+			ARN:         "arn:aws:iam::111111111111:role/ReadOnly",
+			Aliases:     []string{"pr"},
+			Group:       "Production",
+			AccountName: "acme-prod",
+			Tags:        map[string]string{"env": "prod", "team": "data"},
+		},
+		{
+			Name:      "prod-admin",
+			ARN:       "arn:aws:iam::111111111111:role/Admin",
+			Group:     "production",
+			Tags:      map[string]string{"env": "prod"},
+			IsDefault: true,
+		},
+		{Name: "test-developer", ARN: "arn:aws:iam::222222222222:role/Developer", Aliases: []string{"dev", "PR"}},
+	}
+}
+
+func TestRoleListFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter roleListFilter
+		want   string
+	}{
+		{"none", roleListFilter{}, "prod-readonly prod-admin test-developer"},
+		{"account", roleListFilter{account: "111111111111"}, "prod-readonly prod-admin"},
+		{"unknown account", roleListFilter{account: "333333333333"}, ""},
+		{"alias", roleListFilter{alias: "pr"}, "prod-readonly test-developer"},
+		{"name", roleListFilter{name: "PROD"}, "prod-readonly prod-admin"},
+		{"group", roleListFilter{group: "PRODUCTION"}, "prod-readonly prod-admin"},
+		{"tags", roleListFilter{tags: map[string]string{"env": "prod", "team": "data"}}, "prod-readonly"},
+		{"combined", roleListFilter{account: "111111111111", name: "admin"}, "prod-admin"},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, role := range testListRoles() {
+			if test.filter.matches(role) {
+				names = append(names, role.Name)
+			}
+		}
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestNewRoleListEntry(t *testing.T) {
+	roles := testListRoles()
+	store := cachedcredsprovider.NewMemoryStore()
+	store.Put("111111111111-ReadOnly", &cachedcredsprovider.CachedCredentials{
+		RoleARN:   roles[0].ARN,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	store.Put("111111111111-Admin", &cachedcredsprovider.CachedCredentials{
+		RoleARN:   roles[1].ARN,
+		ExpiresAt: time.Now().Add(-time.Hour),
+	})
+
+	tests := []struct {
+		role   config.RoleConfig
+		status string
+	}{
+		{roles[0], cacheStatusValid},
+		{roles[1], cacheStatusExpired},
+		{roles[2], cacheStatusNone},
+	}
+	for _, test := range tests {
+		entry := newRoleListEntry(test.role, store)
+		if entry.CacheStatus != test.status {
+			t.Errorf("Expected %s's cache status to be %s, got %s", test.role.Name, test.status, entry.CacheStatus)
+		}
+		if (test.status == cacheStatusValid) != (entry.TimeRemaining > 0) {
+			t.Errorf("Unexpected time remaining for %s: %d", test.role.Name, entry.TimeRemaining)
+		}
+		if (test.status == cacheStatusNone) != (entry.ExpiresAt == nil) {
+			t.Errorf("Unexpected expiry time for %s: %v", test.role.Name, entry.ExpiresAt)
+		}
+	}
+	if entry := newRoleListEntry(roles[0], store); entry.AccountID != "111111111111" || entry.RoleName != "ReadOnly" {
+		t.Errorf("Expected the account ID and role name from the ARN, got %+v", entry)
+	}
+}
+
+func TestWriteRoleList(t *testing.T) {
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []roleListEntry{
+		{
+			Name:          "prod-readonly",
+			ARN:           "arn:aws:iam::111111111111:role/ReadOnly",
+			AccountID:     "111111111111",
+			AccountName:   "acme-prod",
+			RoleName:      "ReadOnly",
+			Aliases:       []string{"pr", "ro"},
+			Tags:          map[string]string{"env": "prod", "team": "data"},
+			CacheStatus:   cacheStatusValid,
+			ExpiresAt:     &expiresAt,
+			TimeRemaining: 90,
+		},
+		{
+			Name:        "test-developer",
+			ARN:         "arn:aws:iam::222222222222:role/Developer",
+			AccountID:   "222222222222",
+			RoleName:    "Developer",
+			Aliases:     []string{},
+			IsDefault:   true,
+			CacheStatus: cacheStatusNone,
+		},
+	}
+
+	tests := []struct {
+		format  string
+		entries []roleListEntry
+		want    []string
+	}{
+		{"table", entries, []string{
+			"NAME                      GROUP  ACCOUNT                   ROLE       ALIASES  TAGS                " +
+				"CACHE  EXPIRES IN",
+			"prod-readonly                    111111111111 (acme-prod)  ReadOnly   pr,ro    env=prod,team=data  valid  1m30s",
+			"test-developer (default)         222222222222              Developer                               none   -",
+		}},
+		{"table", nil, []string{"No roles found."}},
+		{"json", entries, []string{
+			`"name": "prod-readonly",`,
+			`"aliases": [`,
+			`"expires_at": "2026-01-02T03:04:05Z",`,
+			`"time_remaining_seconds": 90`,
+			`"default": true,`,
+		}},
+		{"json", nil, []string{"[]"}},
+		{"yaml", entries, []string{
+			"- name: prod-readonly",
+			"  tags:\n    env: prod\n    team: data",
+			"  cache_status: none",
+		}},
+		{"csv", entries, []string{
+			"name,arn,account_id,account_name,role_name,group,description,aliases,tags,default,target_aws_profile," +
+				"cache_status,expires_at,time_remaining_seconds",
+			"prod-readonly,arn:aws:iam::111111111111:role/ReadOnly,111111111111,acme-prod,ReadOnly,,,pr;ro," +
+				"env=prod;team=data,false,,valid,2026-01-02T03:04:05Z,90",
+			"test-developer,arn:aws:iam::222222222222:role/Developer,222222222222,,Developer,,,,,true,,none,,0",
+		}},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := writeRoleList(&out, test.format, test.entries); err != nil {
+			t.Errorf("Unable to write the %s role list: %s", test.format, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected the %s role list to contain %q, got:\n%s", test.format, want, out.String())
+			}
+		}
+	}
+
+	if err := writeRoleList(&bytes.Buffer{}, "xml", entries); err == nil || isRoleListFormat("xml") {
+		t.Error("Expected xml to be rejected as an output format")
+	}
+}
@@ -0,0 +1,148 @@
+// Package logging configures roo's structured (log/slog) logger, and makes sure that credentials don't end up in it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Formats supported by Setup.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Redacted replaces secrets in log output.
+const Redacted = "REDACTED"
+
+// sensitiveKeys are attribute keys (lower-cased, with dashes and underscores removed) whose values are always redacted.
+var sensitiveKeys = map[string]bool{
+	"secretaccesskey": true,
+	"sessiontoken":    true,
+	"signintoken":     true,
+	"otp":             true,
+	"mfacode":         true,
+	"tokencode":       true,
+	"password":        true,
+	"passphrase":      true,
+}
+
+// secretValueREs match secrets that might turn up in log messages or values with innocuous keys.
+var secretValueREs = []*regexp.Regexp{
+	// Session tokens and sign-in tokens are long base64(-ish) strings.
+	regexp.MustCompile(`[A-Za-z0-9/+=_-]{100,}`),
+	// Secret access keys are 40 characters of base64 - Which plenty of other things (e.g. git commit hashes) are too, so
+	// they're only redacted after a key that names them (e.g. aws_secret_access_key = ..., or "SecretAccessKey":"...").
+	regexp.MustCompile(`(?i)(secret_?-?access_?-?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}\b`),
+}
+
+// secretAccessKeyRE matches values that are entirely a secret access key. Values that match hexRE aren't redacted, as
+// secret access keys (almost) never are hexadecimal, but commit hashes and the like always are.
+var (
+	secretAccessKeyRE = regexp.MustCompile(`^[A-Za-z0-9/+=]{40}$`)
+	hexRE             = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+)
+
+// Setup configures the default slog logger to write to w in the given format (text or json), at level and above.
+func Setup(w io.Writer, format string, level slog.Level) error {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format '%s' - expected %s or %s", format, FormatText, FormatJSON)
+	}
+	slog.SetDefault(slog.New(&redactingHandler{handler}))
+	return nil
+}
+
+// RedactString replaces anything in s that looks like a secret.
+func RedactString(s string) string {
+	if secretAccessKeyRE.MatchString(s) && !hexRE.MatchString(s) {
+		return Redacted
+	}
+	for _, re := range secretValueREs {
+		s = re.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// sensitiveNameRE matches flag and environment variable names that are likely to have secret values.
+var sensitiveNameRE = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[-_]?key|credential|private[-_]?key)`)
+
+// RedactArgs returns a copy of a command's args with likely secrets replaced: values of flags and environment variable
+// assignments with sensitive names (e.g. --password=x, --password x, MY_TOKEN=x), and anything else that RedactString
+// would redact.
+func RedactArgs(args []string) []string {
+	if args == nil {
+		return nil
+	}
+	redacted := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		name, _, isAssignment := strings.Cut(arg, "=")
+		switch {
+		case redactNext:
+			redacted[i] = Redacted
+			redactNext = false
+		case isAssignment && sensitiveNameRE.MatchString(name):
+			redacted[i] = name + "=" + Redacted
+		case !isAssignment && strings.HasPrefix(arg, "-") && sensitiveNameRE.MatchString(arg):
+			redacted[i] = arg
+			redactNext = true
+		default:
+			redacted[i] = RedactString(arg)
+		}
+	}
+	return redacted
+}
+
+// redactAttr is a slog.HandlerOptions.ReplaceAttr function that redacts sensitive attributes.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(a.Key))
+	if sensitiveKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		// Errors and other values (e.g. AWS SDK structs) can contain secrets too, so we redact their string form.
+		if _, ok := a.Value.Any().(fmt.Stringer); ok {
+			return slog.String(a.Key, RedactString(a.Value.String()))
+		}
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
+
+// redactingHandler redacts secrets in log messages, on top of the attribute redaction done by redactAttr.
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, RedactString(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetupRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, FormatJSON, slog.LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	secretAccessKey := "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	sessionToken := strings.Repeat("FwoGZXIvYXdzEJr", 20)
+
+	slog.Debug("MFA code provided", "otp", "123456")
+	slog.Debug("Credentials retrieved", "access_key_id", "ASIAEXAMPLE", "secret_access_key", secretAccessKey)
+	slog.Debug("Token in the message: " + sessionToken)
+	slog.Error("Request failed", "error", errors.New("bad token "+sessionToken))
+
+	output := buf.String()
+	for _, secret := range []string{"123456", secretAccessKey, sessionToken} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Log output contained a secret: %s", secret)
+		}
+	}
+	if !strings.Contains(output, "ASIAEXAMPLE") {
+		t.Errorf("Log output did not contain the (non-secret) access key ID")
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	secretAccessKey := "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	tests := []struct {
+		in, expected string
+	}{
+		{secretAccessKey, Redacted},
+		{"aws_secret_access_key = " + secretAccessKey, "aws_secret_access_key = " + Redacted},
+		{`{"SecretAccessKey":"` + secretAccessKey + `"}`, `{"SecretAccessKey":"` + Redacted + `"}`},
+		// Other 40 character strings are left alone.
+		{"d2ee390c41f3a5e0b7c8d9e1f2a3b4c5d6e7f8a9", "d2ee390c41f3a5e0b7c8d9e1f2a3b4c5d6e7f8a9"},
+		{"Checked out " + secretAccessKey, "Checked out " + secretAccessKey},
+	}
+	for _, test := range tests {
+		if redacted := RedactString(test.in); redacted != test.expected {
+			t.Errorf("Expected %q to be redacted as %q, got %q", test.in, test.expected, redacted)
+		}
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{
+		"mysql", "--password=hunter2", "--user", "admin", "--api-key", "abc123", "DB_TOKEN=xyz",
+		"wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "--region=ap-southeast-2",
+		// Commit hashes are 40 characters too, but aren't secrets.
+		"git", "checkout", "d2ee390c41f3a5e0b7c8d9e1f2a3b4c5d6e7f8a9",
+	}
+	expected := []string{
+		"mysql", "--password=REDACTED", "--user", "admin", "--api-key", "REDACTED", "DB_TOKEN=REDACTED",
+		"REDACTED", "--region=ap-southeast-2",
+		"git", "checkout", "d2ee390c41f3a5e0b7c8d9e1f2a3b4c5d6e7f8a9",
+	}
+	if actual := RedactArgs(args); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected redacted args:\n%q\nexpected:\n%q", actual, expected)
+	}
+}
+
+func TestSetupRejectsUnknownFormat(t *testing.T) {
+	if err := Setup(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Errorf("Unknown log format did not return an error")
+	}
+}
@@ -1,42 +1,47 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"regexp"
-	"strconv"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/pkg/browser"
 
-	"github.com/jkueh/roo/cachedcredsprovider"
-
-	"github.com/jkueh/roo/config"
-
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/jkueh/roo/audit"
+	"github.com/jkueh/roo/logging"
+	"github.com/jkueh/roo/roo"
 )
 
 var rooVersion string
 
 var debug bool
 var verbose bool
+var logFormat = logging.FormatText
+var logFile string
+
+// requestTimeout limits how long each request to AWS can take. Zero means the config file value (or default) is used.
+var requestTimeout time.Duration
+var homeDir string
 var configDir string
 var configFile string
 
+// legacyConfigDir is where roo kept its config (and cache) prior to following the XDG base directory specification.
+var legacyConfigDir string
+
 // cacheDir is separately configurable, as on some systems you want it to write to /tmp so that the keys are purged
 // after the system is rebooted.
 var cacheDir string
 
+// stateDir is where roo keeps things that should survive a reboot, but aren't config (e.g. the recently used roles).
+var stateDir string
+
 func main() {
 	var baseProfile string
 	var oneTimePasscode string
@@ -47,28 +52,64 @@ func main() {
 	var writeToProfile bool
 	var targetProfile string
 	var openConsoleURL, showConsoleURL bool
+	var mfaSerial string
+	var authSource string
+	var skipConfirmation bool
+
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcommand(os.Args[2:]))
+		}
+	}
 
-	flag.BoolVar(&debug, "debug", false, "Enables debug logging.")
-	flag.BoolVar(&showRoleList, "list", false, "Displays a list of configured roles, then exits.")
+	addCommonFlags(flag.CommandLine)
+	flag.BoolVar(&showRoleList, "list", false, "Deprecated: Use 'roo list' instead.")
 	flag.BoolVar(&showVersionInfo, "version", false, "Show version information.")
 	flag.BoolVar(&tokenNeedsRefresh, "refresh", false, "Force a refresh of all tokens")
-	flag.BoolVar(&verbose, "verbose", false, "Enables verbose logging.")
-	flag.StringVar(&baseProfile, "profile", "", "The base AWS config profile to use when creating the session.")
-	flag.StringVar(&oneTimePasscode, "code", "", "MFA Token OTP - The 6+ digit code that refreshes every 30 seconds.")
-	flag.StringVar(&targetRole, "role", "", "The role name or alias to assume.")
+	flag.StringVar(
+		&baseProfile,
+		"profile",
+		os.Getenv(envProfile),
+		"The base AWS config profile to use when creating the session. (env: "+envProfile+")",
+	)
+	flag.StringVar(
+		&oneTimePasscode,
+		"code",
+		"",
+		"MFA Token OTP - The code that refreshes every 30 seconds. It's 6 digits long, unless mfa_code_length is set "+
+			"in the config file.",
+	)
+	flag.StringVar(&targetRole, "role", os.Getenv(envRole), "The role name or alias to assume. (env: "+envRole+")")
+	flag.StringVar(
+		&mfaSerial,
+		"mfa-serial",
+		os.Getenv(envMFASerial),
+		"The serial ARN of the MFA device to use. (env: "+envMFASerial+")",
+	)
+	flag.StringVar(
+		&authSource,
+		"auth-source",
+		os.Getenv(envAuthSource),
+		"The auth source to assume the role from, overriding the config - e.g. a web_identity source in CI. (env: "+
+			envAuthSource+")",
+	)
 	flag.BoolVar(
 		&writeToProfile,
 		"write-profile",
 		false,
 		"If set, roo will write the credentials to an AWS profile using the AWS CLI.",
 	)
+	flag.BoolVar(
+		&skipConfirmation,
+		"yes",
+		false,
+		"Skips the confirmation prompt for roles flagged as confirm or protected.",
+	)
 	flag.StringVar(&targetProfile, "target-profile", "", "The name of the profile to write credentials for.")
 	flag.BoolVar(&openConsoleURL, "console", false, "Opens an AWS console session")
 	flag.BoolVar(&showConsoleURL, "console-url", false, "Prints the console URL to stdout")
 
-	flag.Parse()
-
-	config.Debug, config.Verbose = debug, verbose
+	parseCommonFlags(flag.CommandLine, os.Args[1:])
 
 	if showVersionInfo {
 		if rooVersion == "" {
@@ -81,196 +122,145 @@ func main() {
 
 	// Some flag debugging
 	if debug {
-		log.Println("Command Line Parameters")
 		flag.VisitAll(func(f *flag.Flag) {
-			log.Println(f.Name+":", "\t", f.Value)
+			value := f.Value.String()
+			if f.Name == "code" && value != "" {
+				value = logging.Redacted
+			}
+			slog.Debug("Command line parameter", "name", f.Name, "value", value)
 		})
-		log.Println()
 	}
 
-	// Ensure we have a role definition for the role
-	conf := config.New(configFile)
-
 	if showRoleList {
-		conf.ListRoles()
-		os.Exit(0)
-	}
-
-	var role *config.RoleConfig
-	if targetRole == "" {
-		// See if we can pull a default
-		role = conf.GetDefaultRole()
-		if role == nil {
-			flag.Usage()
-			log.Fatalln("Role not provided (-role)")
-		}
-	} else {
-		role = conf.GetRole(targetRole)
-	}
-
-	if debug {
-		log.Println("role:", role)
-	}
-
-	if role.ARN == "" {
-		log.Fatalln("Unable to find role by name or alias:", targetRole)
+		os.Exit(runList(nil))
 	}
 
-	// If a base profile wasn't specified on the command line, then try use a default - if configured.
-	if baseProfile == "" {
-		baseProfile = conf.DefaultProfile
-	}
-
-	// The cache file name we use is {{.AccountNumber}}-{{.RoleName}}.json
-	accountNumberRE := regexp.MustCompile("arn:aws:iam::([0-9]+)")
-	accountNumberMatch := accountNumberRE.FindStringSubmatch(role.ARN)
-	if len(accountNumberMatch) == 0 {
-		log.Fatalln("Unable to determine account number from ARN.")
-	}
-	accountNumber := accountNumberMatch[1]
-
-	roleNameRE := regexp.MustCompile(":role/(.*)")
-	roleNameMatch := roleNameRE.FindStringSubmatch(role.ARN)
-	if len(roleNameMatch) == 0 {
-		log.Fatalln("Unable to determine the role name from ARN.")
-	}
-	roleName := roleNameMatch[1]
-
-	if debug {
-		log.Println("Account Number:", accountNumber)
-		log.Println("Role Name:     ", roleName)
+	// Ensure we have a role definition for the role
+	conf := loadConfig()
+
+	client := newClient(conf)
+	// These fall back to the config file values if not set.
+	client.Profile = baseProfile
+	client.MFASerial = mfaSerial
+	client.AuthSource = authSource
+	if oneTimePasscode != "" {
+		if _, err := oneTimePasscodeIsValid(oneTimePasscode, conf.GetMFACodeLength()); err != nil {
+			fatal("Invalid MFA code (-code)", "error", err)
+		}
+		client.MFA = roo.StaticMFACode(oneTimePasscode)
 	}
 
-	cacheFileName := fmt.Sprintf("%s-%s.gob", accountNumber, roleName)
-	cacheFilePath := strings.Join([]string{cacheDir, cacheFileName}, string(os.PathSeparator))
-
-	// Define our credential providers
-	cachedProvider := cachedcredsprovider.New(cacheFilePath)
-	if debug {
-		currentCreds, _ := cachedProvider.Retrieve()
-		if currentCreds.AccessKeyID != "" {
-			log.Println("Current Access Key ID:", currentCreds.AccessKeyID)
+	role, err := client.ResolveRole(targetRole)
+	if errors.Is(err, roo.ErrNoRole) {
+		tty, ttyErr := openTerminal()
+		if ttyErr != nil {
+			flag.Usage()
+			fatal("Role not provided (-role)", "error", ttyErr)
 		}
-	}
-
-	if !tokenNeedsRefresh {
-		tokenNeedsRefresh = cachedProvider.IsExpired()
-		if debug && tokenNeedsRefresh {
-			log.Println("Refresh required - cachedProvider indicated credentials are expired.")
+		// We've got a human on the other end - Let them pick one.
+		role, err = pickRole(conf, loadRecentRoles(recentRolesFilePath()), tty.in, tty.out)
+		tty.Close()
+		if err != nil {
+			fatal("Role not provided (-role)", "error", err)
 		}
+	} else if err != nil {
+		fatal("Unable to find role", "error", err)
 	}
 
-	if tokenNeedsRefresh && oneTimePasscode == "" {
-		oneTimePasscodePrompts := 0
-		oneTimePasscodeValid := false
-		var oneTimePasscodeValidationError error
-		for oneTimePasscodePrompts < 3 && !oneTimePasscodeValid {
-			oneTimePasscodeInput := getStringInputFromUser("MFA Code")
-
-			// Ensure that trailing newline characters are removed (e.g. Windows will add \r at the end)
-			oneTimePasscode = strings.TrimRight(oneTimePasscodeInput, "\r\n")
+	slog.Debug("Found role", "name", role.Name, "arn", role.ARN)
 
-			if debug {
-				log.Println("MFA Code Provided:", oneTimePasscode)
-			}
-			oneTimePasscodeValid, oneTimePasscodeValidationError = oneTimePasscodeIsValid(oneTimePasscode)
-			if oneTimePasscodeValidationError != nil {
-				log.Println("Invalid MFA Code:", oneTimePasscodeValidationError)
-			}
-			oneTimePasscodePrompts++
-		}
-		if !oneTimePasscodeValid {
-			fmt.Println("Error: Please provide the MFA Token code (OTP) via the '-code' parameter.")
-			os.Exit(1)
+	// Check the command against the role's command policy before we go prompting for anything.
+	if !writeToProfile && !openConsoleURL && !showConsoleURL && len(flag.Args()) > 0 {
+		if err := role.CheckCommand(flag.Args()); err != nil {
+			fatal("Command blocked", "error", err)
 		}
 	}
 
-	// The static credentials we'll use to build the targetRole session
-
-	// At this point - Work out if we need to load the initial credentials for the authentication account, or if we can
-	// jump straight to exporting the existing tokens.
-	if tokenNeedsRefresh {
-		// Time to do the hard work - Get to the point where we can cache credentials to disk.
-		authAccountSessionOpts := session.Options{}
-		if baseProfile != "" {
-			authAccountSessionOpts.Profile = baseProfile
-		}
-		authAccountSession, err := session.NewSessionWithOptions(authAccountSessionOpts)
-		if err != nil {
-			log.Fatalln("Unable to create a session for the authentication account:", err)
-		}
-		stsClient := sts.New(authAccountSession)
-		callerIdentityOutput, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
-		if err != nil {
-			log.Fatalln("An error occurred while trying to get caller identity:", err)
-		}
-		if verbose {
-			log.Println("Hello world, I'm", *callerIdentityOutput.Arn, "- Time to assume another role!")
-		}
-		timeNow := time.Now()
-		timeNowUnixNanoString := strconv.FormatInt(timeNow.UnixNano(), 10)
-		assumeRoleInput := &sts.AssumeRoleInput{
-			SerialNumber:    aws.String(conf.MFASerial),
-			TokenCode:       aws.String(oneTimePasscode),
-			RoleArn:         aws.String(role.ARN),
-			RoleSessionName: aws.String("roo-" + timeNowUnixNanoString),
-		}
-		assumeRoleOutput, err := stsClient.AssumeRole(assumeRoleInput)
-		if err != nil {
-			log.Fatalln("An error occurred while trying to assume the target role:", err)
-		}
-		if verbose {
-			log.Println("We have successfully assumed the role:", *assumeRoleOutput.AssumedRoleUser.Arn)
-		}
-
-		// Okay, time to build the cachedCredentials object.
-		err = cachedProvider.WriteNewCredentialsFromSTS(assumeRoleOutput.Credentials, cacheFilePath)
-		if err != nil {
-			log.Println("WARNING: An error occurred while trying to write new credentials to the cache file:", err)
+	if role.RequiresConfirmation() && !skipConfirmation {
+		var action string
+		if writeToProfile {
+			action = "write its credentials to an AWS profile"
+		} else if openConsoleURL || showConsoleURL {
+			action = "open an AWS console session"
 		} else {
-			if debug {
-				log.Println("New credentials written - New Access Key ID:", *assumeRoleOutput.Credentials.AccessKeyId)
-			}
+			action = fmt.Sprintf("run '%s'", strings.Join(flag.Args(), " "))
 		}
-	} else {
-		if verbose {
-			log.Println("Using cached credentials!")
+		if err := confirmRoleUsage(role, action); err != nil {
+			fatal("Aborting", "error", err)
 		}
 	}
 
-	// Now we use the new cachedProvider to export our environment variables
-	retrievedCreds, err := cachedProvider.Retrieve()
+	if err := recordRecentRole(recentRolesFilePath(), role.ARN); err != nil {
+		slog.Debug("Unable to record recently used role", "error", err)
+	}
+
+	// Cancel any in-flight requests to AWS on Ctrl-C, rather than waiting for them to time out. Commands that we run
+	// still receive the signal from the terminal, and we wait for them to exit.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rooSession, err := client.AssumeRole(ctx, role, tokenNeedsRefresh)
 	if err != nil {
-		log.Fatalln("Unable to retrieve credentials:", err)
+		fatal("Unable to get credentials for the role", "role", role.Name, "error", err)
 	}
-	if debug {
-		log.Println("Retrieved credentials with Access Key ID", retrievedCreds.AccessKeyID)
+	if rooSession.Refreshed {
+		slog.Info("We have successfully assumed the role", "session_arn", rooSession.SessionARN)
+	} else {
+		slog.Info("Using cached credentials")
 	}
 
+	retrievedCreds := rooSession.Credentials
+	slog.Debug("Retrieved credentials", "access_key_id", retrievedCreds.AccessKeyID)
+
 	os.Setenv("AWS_ACCESS_KEY_ID", retrievedCreds.AccessKeyID)
 	os.Setenv("AWS_SECRET_ACCESS_KEY", retrievedCreds.SecretAccessKey)
 	os.Setenv("AWS_SESSION_TOKEN", retrievedCreds.SessionToken)
 
-	if debug {
-		staticCredentials := credentials.NewStaticCredentialsFromCreds(retrievedCreds)
-		stsSession := session.Must(session.NewSessionWithOptions(session.Options{
-			Config: aws.Config{
-				Credentials: staticCredentials,
-			},
-		}))
-
-		stsClient := sts.New(stsSession)
+	// Let the commands we run know which role they're running as.
+	for key, value := range role.EnvironmentVariables() {
+		os.Setenv(key, value)
+	}
 
-		callerIdentityOutput, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if debug {
+		// Use the provider, rather than the credentials we already have, so that it gets exercised too.
+		provider, err := client.Provider(role)
 		if err != nil {
-			log.Fatalln(
-				"An error occurred while trying to get caller identity when working out who we have credentials for:",
-				err,
-			)
+			fatal("Unable to create a credentials provider for the role", "error", err)
 		}
-		if verbose {
-			log.Println("Hello world, I'm", *callerIdentityOutput.Arn)
+		callerIdentityOutput, err := client.CallerIdentity(ctx, provider)
+		if err != nil {
+			fatal(
+				"An error occurred while trying to get caller identity when working out who we have credentials for",
+				"error", err,
+			)
 		}
+		slog.Info("Hello world", "caller_arn", *callerIdentityOutput.Arn)
+	}
+
+	auditEvent := audit.Event{
+		RoleName:       role.Name,
+		RoleARN:        role.ARN,
+		SessionARN:     rooSession.SessionARN,
+		SourceIdentity: rooSession.SourceIdentity,
+		CacheHit:       !rooSession.Refreshed,
+	}
+	if writeToProfile {
+		auditEvent.Mode = audit.ModeWriteProfile
+		auditEvent.TargetProfile = targetProfile
+		if auditEvent.TargetProfile == "" {
+			auditEvent.TargetProfile = role.TargetAWSProfile
+		}
+	} else if showConsoleURL {
+		auditEvent.Mode = audit.ModeConsoleURL
+	} else if openConsoleURL {
+		auditEvent.Mode = audit.ModeConsole
+	} else {
+		auditEvent.Mode = audit.ModeExec
+		auditEvent.Command = flag.Args()
+	}
+	// Commands are only logged once we know there's one to run (below).
+	if auditEvent.Mode != audit.ModeExec {
+		writeAuditEvent(conf, auditEvent)
 	}
 
 	// There is an exception to evaluating commands - And that's if we've been asked to write these credentials to file.
@@ -279,8 +269,7 @@ func main() {
 		var targetProfileName string // We'll need to coalesce through some config , combined with flags.
 
 		if targetProfile == "" && role.TargetAWSProfile == "" {
-			log.Println("Please specify a target profile with -target-profile, or by specifying it in the config file.")
-			os.Exit(1)
+			fatal("Please specify a target profile with -target-profile, or by specifying it in the config file.")
 		}
 
 		if targetProfile != "" {
@@ -289,131 +278,54 @@ func main() {
 			targetProfileName = role.TargetAWSProfile
 		}
 
-		if verbose {
-			log.Println("We're going to write to profile! The profile's named", targetProfileName)
-		}
-		// Step 0 is to check that we have the AWS executable somewhere in the PATH.
-		cliPath, err := exec.LookPath("aws")
-		if err != nil {
-			log.Fatalln("Unable to find AWS CLI executable in PATH:", err)
-		}
-		if debug {
-			log.Println("Found it!", cliPath)
-		}
-
-		// Okay, time to execute the commands we need to execute.
-		baseCommand := []string{cliPath, "--profile", targetProfileName, "configure", "set"}
-
-		// Apologies for all the spread operators...
-
-		// Set: Access Key ID
-		err = executeCommand(append(baseCommand, []string{"aws_access_key_id", retrievedCreds.AccessKeyID}...)...)
-		if err != nil {
-			log.Println("An error occurred while trying to write the aws_access_key_id to file:", err)
-		}
-
-		// Set: Secret Access Key
-		err = executeCommand(append(baseCommand, []string{"aws_secret_access_key", retrievedCreds.SecretAccessKey}...)...)
-		if err != nil {
-			log.Println("An error occurred while trying to write the aws_secret_access_key to file:", err)
-		}
-
-		// Set: Session Token
-		err = executeCommand(append(baseCommand, []string{"aws_session_token", retrievedCreds.SessionToken}...)...)
-		if err != nil {
-			log.Println("An error occurred while trying to write the aws_access_key_id to file:", err)
-		}
-
-		// Set: Expiration Timestamp - Not used by the AWS CLI, but will allow the user to check.
-		err = executeCommand(append(baseCommand, []string{
-			"expiration_time", cachedProvider.GetCredentialExpiryTime().String()}...)...,
+		slog.Info("We're going to write to profile!", "profile", targetProfileName)
+		// The credentials file is edited directly, rather than with 'aws configure set', so that the secrets never
+		// appear on a command line.
+		err := setProfileCredentials(
+			awsCredentialsFile(),
+			targetProfileName,
+			credentialsSetting{"aws_access_key_id", retrievedCreds.AccessKeyID},
+			credentialsSetting{"aws_secret_access_key", retrievedCreds.SecretAccessKey},
+			credentialsSetting{"aws_session_token", retrievedCreds.SessionToken},
+			// Not used by the AWS CLI, but will allow the user to check.
+			credentialsSetting{"expiration_time", rooSession.ExpiresAt.String()},
 		)
 		if err != nil {
-			log.Println("An error occurred while trying to write the aws_access_key_id to file:", err)
+			fatal("Unable to write the credentials to the profile", "profile", targetProfileName, "error", err)
 		}
 
 		fmt.Println("Profile written:", targetProfileName)
 	} else if openConsoleURL || showConsoleURL { // We also skip command execution if
-		// Step one... Is to build the URL.
-		request, err := http.NewRequest(http.MethodGet, "https://signin.aws.amazon.com/federation", nil)
-		if err != nil {
-			log.Fatalln("Unable to construct request to federation endpoint:", err)
-		}
-		sessionData := map[string]string{
-			"sessionId":    retrievedCreds.AccessKeyID,
-			"sessionKey":   retrievedCreds.SecretAccessKey,
-			"sessionToken": retrievedCreds.SessionToken,
-		}
-		sessionDataJSON, err := json.Marshal(&sessionData)
-		if err != nil {
-			log.Fatalln("Unable to build session credentials for federation endpoint call:", err)
-		}
-		query := request.URL.Query()
-		query.Add("Action", "getSigninToken")
-		query.Add("SessionDuration", "43200")
-		query.Add("Session", string(sessionDataJSON))
-		request.URL.RawQuery = query.Encode()
-
-		resp, err := http.DefaultClient.Do(request)
-		if err != nil {
-			log.Fatalln("An error occurred while retrieving data from federation endpoint:", err)
-		}
-
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalln("An error occurred while trying to read the response body from the federation endpoint:", err)
-		}
-
-		var signInTokenResponse SigninTokenResponse
-		err = json.Unmarshal(respBody, &signInTokenResponse)
-		if err != nil {
-			log.Fatalln("Unable to unmarshal sign-in token response to JSON:", err)
-		}
-
-		consoleURLRequest, err := http.NewRequest(http.MethodGet, "https://signin.aws.amazon.com/federation", nil)
+		urlString, err := client.SessionConsoleURL(ctx, retrievedCreds, roo.ConsoleURLOptions{
+			Issuer: fmt.Sprintf("roo-%s", rooVersion),
+		})
 		if err != nil {
-			log.Fatalln("Unable to construct console sign-in URL:", err)
+			fatal("Unable to build the console sign-in URL", "error", err)
 		}
-		consoleURLRequestQuery := consoleURLRequest.URL.Query()
-		consoleURLRequestQuery.Add("Action", "login")
-		consoleURLRequestQuery.Add("Issuer", "Example.org")
-		consoleURLRequestQuery.Add("Issuer", fmt.Sprintf("roo-%s", rooVersion))
-		consoleURLRequestQuery.Add("Destination", "https://console.aws.amazon.com/")
-		consoleURLRequestQuery.Add("SigninToken", signInTokenResponse.SigninToken)
-
-		consoleURLRequest.URL.RawQuery = consoleURLRequestQuery.Encode()
-
-		urlString := consoleURLRequest.URL.String()
 
 		if showConsoleURL { // If we're only asked to show it, print it and call it a day.
 			fmt.Println(urlString)
 		} else if openConsoleURL {
-			if debug {
-				log.Println("SigninToken:", signInTokenResponse.SigninToken)
-			}
 			err := browser.OpenURL(urlString)
 			if err != nil {
-				log.Fatalln("An error occurred while trying to get the system to open the console URL:", err)
+				fatal("An error occurred while trying to get the system to open the console URL", "error", err)
 			}
 		} else {
-			log.Fatalln("Unhandled scenario: Not showConsoleURL or openConsoleURL")
+			fatal("Unhandled scenario: Not showConsoleURL or openConsoleURL")
 		}
 	} else {
-		if debug {
-			log.Println("flag.Args() length:", len(flag.Args()))
-		}
+		slog.Debug("Parsed command", "args", len(flag.Args()))
 		if len(flag.Args()) == 0 { // Let's make sure we have something to run here...
 			// println() for STDERR output
 			println("Please provide a command to execute, e.g.:")
 			println("roo -role my_role_name aws sts get-caller-identity")
 			os.Exit(100)
 		}
-		if debug {
-			log.Println("We're going to want to run the following command:", flag.Args())
-		}
+		writeAuditEvent(conf, auditEvent)
+		slog.Debug("We're going to want to run the following command", "command", logging.RedactArgs(flag.Args()))
 		err := executeCommand(flag.Args()...)
 		if err != nil {
-			log.Println("An error occurred while trying to execute command:", err)
+			slog.Error("An error occurred while trying to execute command", "error", err)
 		}
 	}
 }
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"rsc.io/qr"
+
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+// qrQuietZone is the number of light modules drawn around QR codes, which scanners need to find their edges.
+const qrQuietZone = 4
+
+// mfaSubcommands maps the name of each 'roo mfa' subcommand to the function that runs it.
+var mfaSubcommands = map[string]func(args []string) int{
+	"enroll": runMFAEnroll,
+	"detect": runMFADetect,
+}
+
+// runMFA implements 'roo mfa', which sets up MFA devices.
+func runMFA(args []string) int {
+	if len(args) > 0 {
+		if subcommand, ok := mfaSubcommands[args[0]]; ok {
+			return subcommand(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: roo mfa enroll|detect [flags]")
+	return 2
+}
+
+// runMFAEnroll implements 'roo mfa enroll', which creates and enables a virtual MFA device for the IAM user that an
+// auth source logs in as, then configures roo to use it.
+func runMFAEnroll(args []string) int {
+	flags := flag.NewFlagSet("mfa enroll", flag.ExitOnError)
+	addCommonFlags(flags)
+	var sourceName, baseProfile, deviceName string
+	var storeSeed bool
+	addMFAFlags(flags, &sourceName, &baseProfile)
+	flags.StringVar(&deviceName, "name", "", "The name of the new MFA device. Defaults to the IAM user's name.")
+	flags.BoolVar(
+		&storeSeed,
+		"store-seed",
+		false,
+		"Keep the device's seed in the auth source's key backend, so that roo can generate MFA codes itself "+
+			"(See mfa_code_source: totp).",
+	)
+	parseCommonFlags(flags, args)
+	conf, source := mfaSourceArg(flags, sourceName)
+	if source == nil {
+		return 2
+	}
+	if storeSeed && source.KeyBackend == "" {
+		fmt.Fprintln(os.Stderr, "-store-seed needs an auth source with a key_backend to keep the seed in.")
+		return 2
+	}
+
+	client := newClient(conf)
+	client.Profile = baseProfile
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	device, err := client.EnrollMFADevice(ctx, source.Name, deviceName, storeSeed,
+		func(ctx context.Context, device *roo.VirtualMFADevice) (string, string, error) {
+			return collectEnrollmentCodes(ctx, device, storeSeed, conf.GetMFACodeLength())
+		},
+	)
+	if err != nil {
+		fatal("Unable to enroll an MFA device", "error", err)
+	}
+	fmt.Printf("Enabled MFA device %s for %s.\n", device.Serial, device.UserARN)
+	if storeSeed {
+		fmt.Printf("Its seed is stored in %s.\n", source.KeyBackend)
+	}
+	return saveMFASerial(source, device.Serial, storeSeed)
+}
+
+// runMFADetect implements 'roo mfa detect', which configures roo to use an MFA device that's already enabled for the
+// IAM user that an auth source logs in as.
+func runMFADetect(args []string) int {
+	flags := flag.NewFlagSet("mfa detect", flag.ExitOnError)
+	addCommonFlags(flags)
+	var sourceName, baseProfile string
+	addMFAFlags(flags, &sourceName, &baseProfile)
+	parseCommonFlags(flags, args)
+	conf, source := mfaSourceArg(flags, sourceName)
+	if source == nil {
+		return 2
+	}
+
+	client := newClient(conf)
+	client.Profile = baseProfile
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serials, err := client.MFADevices(ctx, source.Name)
+	if err != nil {
+		fatal("Unable to detect MFA devices", "error", err)
+	}
+	if len(serials) == 0 {
+		fmt.Fprintln(os.Stderr, "No MFA devices are enabled for your IAM user - Run 'roo mfa enroll' to add one.")
+		return 1
+	}
+	serial := serials[0]
+	if len(serials) > 1 {
+		if serial, err = selectMFADevice(ctx, source.Name, serials); err != nil {
+			fatal("Unable to select an MFA device", "error", err)
+		}
+	}
+	fmt.Printf("Found MFA device %s.\n", serial)
+	return saveMFASerial(source, serial, false)
+}
+
+// addMFAFlags registers the flags shared by the 'roo mfa' subcommands.
+func addMFAFlags(flags *flag.FlagSet, sourceName, baseProfile *string) {
+	flags.StringVar(sourceName, "auth-source", "", "The auth source to set up MFA for. Defaults to the default one.")
+	flags.StringVar(
+		baseProfile,
+		"profile",
+		os.Getenv(envProfile),
+		"The base AWS config profile to use. (env: "+envProfile+")",
+	)
+}
+
+// mfaSourceArg loads the config, and returns the auth source named by sourceName, or the default auth source if it's
+// empty. If there are any arguments, or the auth source isn't configured, the problem is printed and a nil auth source
+// is returned.
+func mfaSourceArg(flags *flag.FlagSet, sourceName string) (*config.Config, *config.AuthSource) {
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: roo %s [flags]\n", flags.Name())
+		flags.PrintDefaults()
+		return nil, nil
+	}
+	conf := loadConfig()
+	if sourceName == "" {
+		source, err := conf.GetAuthSource(nil)
+		if err != nil {
+			fatal("Unable to find the default auth source", "error", err)
+		}
+		return conf, source
+	}
+	source := conf.GetNamedAuthSource(sourceName)
+	if source == nil {
+		fmt.Fprintf(os.Stderr, "Auth source '%s' isn't configured (See auth_sources in %s).\n", sourceName, configFile)
+		return nil, nil
+	}
+	return conf, source
+}
+
+// collectEnrollmentCodes shows the user a new MFA device's QR code and seed, so that they can add it to their
+// authenticator app, then returns two consecutive codes from it. If the seed is being stored, the codes are generated
+// from it rather than asked for - In which case there doesn't need to be a terminal, and the seed is written to stderr
+// instead, so that the user can still add the device to their app. Codes that the user enters must be codeLength
+// digits long. If ctx is cancelled while the user is being asked, ctx.Err() is returned, so that the device is deleted
+// again.
+func collectEnrollmentCodes(
+	ctx context.Context,
+	device *roo.VirtualMFADevice,
+	storeSeed bool,
+	codeLength int,
+) (string, string, error) {
+	tty, err := openTerminal()
+	if err != nil && !(storeSeed && isPromptError(err)) {
+		return "", "", err
+	}
+	if tty != nil {
+		defer tty.Close()
+		fmt.Fprintln(tty.out, "Scan this QR code with your authenticator app:")
+		if err := renderQRCode(tty.out, device.URI()); err != nil {
+			return "", "", err
+		}
+		fmt.Fprintf(tty.out, "Or enter this seed into it: %s\n", device.Seed)
+	} else {
+		fmt.Fprintf(os.Stderr, "To use the MFA device in your authenticator app too, enter this seed into it: %s\n",
+			device.Seed)
+	}
+
+	if storeSeed {
+		// AWS needs codes from two consecutive periods, so use the previous one along with the current one.
+		now := time.Now()
+		code1, err := roo.TOTPCode(device.Seed, now.Add(-30*time.Second))
+		if err != nil {
+			return "", "", err
+		}
+		code2, err := roo.TOTPCode(device.Seed, now)
+		return code1, code2, err
+	}
+	code1, err := readEnrollmentCode(ctx, tty, "First MFA code", codeLength)
+	if err != nil {
+		return "", "", err
+	}
+	fmt.Fprintln(tty.out, "Wait for the code to change, then enter the next one.")
+	for {
+		code2, err := readEnrollmentCode(ctx, tty, "Next MFA code", codeLength)
+		if err != nil || code2 != code1 {
+			return code1, code2, err
+		}
+		fmt.Fprintln(tty.out, "That's the same code - Wait for it to change.")
+	}
+}
+
+// readEnrollmentCode asks the user for a codeLength digit code from a new MFA device, until they enter one that looks
+// valid or ctx is cancelled.
+func readEnrollmentCode(ctx context.Context, tty *terminal, prompt string, codeLength int) (string, error) {
+	for {
+		code, err := tty.readWithContext(ctx, func() (string, error) {
+			return tty.readLine(prompt)
+		})
+		if err != nil {
+			return "", err
+		}
+		code = strings.TrimSpace(code)
+		_, err = oneTimePasscodeIsValid(code, codeLength)
+		if err == nil {
+			return code, nil
+		}
+		fmt.Fprintln(tty.out, err)
+	}
+}
+
+// renderQRCode writes text to w as a QR code, drawn with block characters so that each line of text holds two rows of
+// modules. Light modules are drawn, rather than dark ones, as most terminals have a dark background.
+func renderQRCode(w io.Writer, text string) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return err
+	}
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+			return true
+		}
+		return !code.Black(x, y)
+	}
+	var out strings.Builder
+	for y := -qrQuietZone; y < code.Size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < code.Size+qrQuietZone; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				out.WriteString("█")
+			case top:
+				out.WriteString("▀")
+			case bottom:
+				out.WriteString("▄")
+			default:
+				out.WriteString(" ")
+			}
+		}
+		out.WriteString("\n")
+	}
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// saveMFASerial configures source to use the MFA device with the given serial. The top-level mfa_serial is set in the
+// config file if source is the implicit auth source. Otherwise, the user is told how to change the auth source, as
+// roo doesn't rewrite auth_sources. The exit code is returned.
+func saveMFASerial(source *config.AuthSource, serial string, storeSeed bool) int {
+	if source.Name != "" {
+		fmt.Printf("Add it to the mfa_serials of auth source '%s' in %s:\n", source.Name, configFile)
+		fmt.Printf("  mfa_serials:\n    - %s\n", serial)
+		if storeSeed && source.MFACodeSource != config.MFACodeSourceTOTP {
+			fmt.Printf("  mfa_code_source: %s\n", config.MFACodeSourceTOTP)
+		}
+		return 0
+	}
+	if err := config.SetMFASerial(configFile, serial); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set mfa_serial in %s: %s\n", configFile, err)
+		return 1
+	}
+	fmt.Printf("Set mfa_serial in %s.\n", configFile)
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jkueh/roo/roo"
+)
+
+func TestRenderQRCode(t *testing.T) {
+	var out strings.Builder
+	if err := renderQRCode(&out, "otpauth://totp/Amazon%20Web%20Services:someone@000000000000?secret=ABC"); err != nil {
+		t.Fatalf("Unable to render the QR code: %s", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	width := utf8.RuneCountInString(lines[0])
+	// Each line holds two rows of modules, so a square code is about half as tall as it's wide.
+	if len(lines) != (width+1)/2 {
+		t.Errorf("Expected %d lines for a code %d modules wide, got %d", (width+1)/2, width, len(lines))
+	}
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) != width {
+			t.Errorf("Line %d is %d characters wide, rather than %d", i, utf8.RuneCountInString(line), width)
+		}
+	}
+	if strings.Trim(lines[0], "█") != "" || strings.Trim(lines[1], "█") != "" {
+		t.Error("Expected the code to be surrounded by a light quiet zone")
+	}
+}
+
+func TestReadEnrollmentCodeCancelled(t *testing.T) {
+	// Nothing is ever written to the pipe, so reading from it blocks, as it would waiting for the user.
+	in, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer writer.Close()
+	out, err := os.CreateTemp(t.TempDir(), "tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	fakeTerminalState(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = readEnrollmentCode(ctx, &terminal{in: in, out: out}, "First MFA code", 6)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, so that the device is rolled back, got %v", err)
+	}
+}
+
+func TestReadEnrollmentCodeLength(t *testing.T) {
+	fakeTerminalState(t)
+	tty, _ := answeringTerminal(t, "12345678\n")
+	if code, err := readEnrollmentCode(context.Background(), tty, "First MFA code", 8); err != nil || code != "12345678" {
+		t.Errorf("Expected the 8 digit code, got %q, %v", code, err)
+	}
+
+	tty, output := answeringTerminal(t, "123456\n")
+	if _, err := readEnrollmentCode(context.Background(), tty, "First MFA code", 8); err == nil {
+		t.Error("A 6 digit code was accepted")
+	}
+	if !strings.Contains(output(), "must be 8 digits long") {
+		t.Errorf("The 6 digit code wasn't rejected for its length: %q", output())
+	}
+}
+
+func TestCollectEnrollmentCodesWithoutTerminal(t *testing.T) {
+	noPrompt = true
+	t.Cleanup(func() { noPrompt = false })
+	stderr := os.Stderr
+	t.Cleanup(func() { os.Stderr = stderr })
+	var err error
+	if os.Stderr, err = os.CreateTemp(t.TempDir(), "stderr"); err != nil {
+		t.Fatal(err)
+	}
+
+	device := &roo.VirtualMFADevice{Seed: "JBSWY3DPEHPK3PXP"}
+	code1, code2, err := collectEnrollmentCodes(context.Background(), device, true, 6)
+	if err != nil || len(code1) != 6 || len(code2) != 6 {
+		t.Errorf("Unexpected codes from the stored seed: %q, %q, %v", code1, code2, err)
+	}
+	written, err := os.ReadFile(os.Stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(written), device.Seed) {
+		t.Errorf("The seed wasn't written to stderr: %q", written)
+	}
+
+	if _, _, err := collectEnrollmentCodes(context.Background(), device, false, 6); !isPromptError(err) {
+		t.Errorf("Expected a prompt error when the codes need to be entered, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/jkueh/roo/config"
+)
+
+// pickerMaxRows is the number of roles the picker displays at once.
+const pickerMaxRows = 10
+
+// errPickerCancelled is returned when the user backs out of the role picker.
+var errPickerCancelled = errors.New("role selection cancelled")
+
+// rolePicker holds the state for the interactive role picker.
+type rolePicker struct {
+	// conf is a copy of the config with the roles in the order they were most recently used, so that roles that match
+	// the query equally well (including every role, when there's no query) are listed by recency.
+	conf     *config.Config
+	query    string
+	matches  []config.RoleConfig
+	selected int
+}
+
+// newRolePicker returns a role picker for the roles in conf, given the ARNs of the most recently used roles (most
+// recent first).
+func newRolePicker(conf *config.Config, recent []string) *rolePicker {
+	recency := map[string]int{}
+	for i, arn := range recent {
+		recency[arn] = len(recent) - i
+	}
+	byRecency := *conf
+	byRecency.Roles = append([]config.RoleConfig(nil), conf.Roles...)
+	sort.SliceStable(byRecency.Roles, func(i, j int) bool {
+		return recency[byRecency.Roles[i].ARN] > recency[byRecency.Roles[j].ARN]
+	})
+	picker := &rolePicker{conf: &byRecency}
+	picker.filter()
+	return picker
+}
+
+// pickRole presents an interactive, fuzzy-searchable list of the configured roles, returning the one that the user
+// selects. Roles are ordered by how well they match the query, then by how recently they were used.
+func pickRole(conf *config.Config, recent []string, in *os.File, out io.Writer) (*config.RoleConfig, error) {
+	if len(conf.Roles) == 0 {
+		return nil, errors.New("no roles have been configured")
+	}
+
+	oldState, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(int(in.Fd()), oldState)
+
+	picker := newRolePicker(conf, recent)
+	picker.render(out)
+	defer picker.clear(out)
+
+	reader := bufio.NewReader(in)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		switch r {
+		case '\r', '\n':
+			if len(picker.matches) == 0 {
+				continue
+			}
+			return &picker.matches[picker.selected], nil
+		case 3, 4: // Ctrl-C, Ctrl-D
+			return nil, errPickerCancelled
+		case 27: // Escape - Either on its own, or the start of an arrow key sequence.
+			if reader.Buffered() == 0 {
+				return nil, errPickerCancelled
+			}
+			sequence := make([]byte, 2)
+			if _, err := io.ReadFull(reader, sequence); err != nil {
+				return nil, err
+			}
+			switch string(sequence) {
+			case "[A":
+				picker.move(-1)
+			case "[B":
+				picker.move(1)
+			}
+		case 16: // Ctrl-P
+			picker.move(-1)
+		case 14: // Ctrl-N
+			picker.move(1)
+		case 127, 8: // Backspace
+			if len(picker.query) > 0 {
+				queryRunes := []rune(picker.query)
+				picker.query = string(queryRunes[:len(queryRunes)-1])
+				picker.filter()
+			}
+		case 21: // Ctrl-U
+			picker.query = ""
+			picker.filter()
+		default:
+			if r >= ' ' {
+				picker.query += string(r)
+				picker.filter()
+			}
+		}
+		picker.render(out)
+	}
+}
+
+// filter recalculates the list of matching roles for the current query.
+func (p *rolePicker) filter() {
+	p.matches = p.conf.SearchRoles(p.query)
+	p.selected = 0
+}
+
+// move shifts the selection by delta, wrapping around at either end.
+func (p *rolePicker) move(delta int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.selected = (p.selected + delta + len(p.matches)) % len(p.matches)
+}
+
+// clear removes the picker from the terminal. The cursor is always left on the query line after rendering, so it's
+// just a matter of clearing from there to the end of the screen.
+func (p *rolePicker) clear(out io.Writer) {
+	fmt.Fprint(out, "\r\x1b[J")
+}
+
+// render draws the query and the visible portion of the matching roles.
+func (p *rolePicker) render(out io.Writer) {
+	p.clear(out)
+
+	// Scroll the window so that the selection is always visible.
+	start := 0
+	if p.selected >= pickerMaxRows {
+		start = p.selected - pickerMaxRows + 1
+	}
+	end := start + pickerMaxRows
+	if end > len(p.matches) {
+		end = len(p.matches)
+	}
+
+	var lines []string
+	for i := start; i < end; i++ {
+		role := p.matches[i]
+		cursor := "  "
+		if i == p.selected {
+			cursor = "> "
+		}
+		line := cursor + role.Name + "  " + role.ARN
+		if len(role.Aliases) > 0 {
+			line += "  (" + strings.Join(role.Aliases, ", ") + ")"
+		}
+		if len(role.Tags) > 0 {
+			line += "  [" + role.FormatTags() + "]"
+		}
+		if role.Description != "" {
+			line += "  - " + role.Description
+		}
+		if i == p.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("  %d/%d roles - Up/Down to select, Enter to assume, Esc to cancel",
+		len(p.matches), len(p.conf.Roles)))
+
+	fmt.Fprint(out, "\r\n"+strings.Join(lines, "\r\n"))
+	// Move the cursor back up to the query line, so that it's where the user expects to be typing.
+	fmt.Fprintf(out, "\r\x1b[%dA", len(lines))
+	fmt.Fprint(out, "Role: "+p.query)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jkueh/roo/config"
+)
+
+func TestRolePickerOrdering(t *testing.T) {
+	conf := &config.Config{Roles: []config.RoleConfig{
+		{Name: "prod-readonly", ARN: "arn:aws:iam::111111111111:role/ReadOnly"},
+		{Name: "prod-admin", ARN: "arn:aws:iam::111111111111:role/Admin"},
+		{Name: "test-developer", ARN: "arn:aws:iam::222222222222:role/Developer"},
+		{Name: "staging-readonly", ARN: "arn:aws:iam::333333333333:role/ReadOnly"},
+	}}
+	recent := []string{
+		"arn:aws:iam::111111111111:role/Admin",
+		"arn:aws:iam::222222222222:role/Developer",
+		"arn:aws:iam::111111111111:role/ReadOnly",
+	}
+
+	names := func(roles []config.RoleConfig) []string {
+		var names []string
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+		return names
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// With no query, the most recently used roles come first, then the rest in the order they're configured.
+		{"", []string{"prod-admin", "test-developer", "prod-readonly", "staging-readonly"}},
+		// Roles that match equally well are listed by recency.
+		{"prod", []string{"prod-admin", "prod-readonly"}},
+		// A better match beats a more recent one ("readonly" is scattered across "prod-readonly").
+		{"readonly", []string{"staging-readonly", "prod-readonly"}},
+		{"prod-read", []string{"prod-readonly"}},
+	}
+	for _, test := range tests {
+		picker := newRolePicker(conf, recent)
+		picker.query = test.query
+		picker.filter()
+		got := names(picker.matches)
+		if len(got) < len(test.want) {
+			t.Errorf("Expected %q to match %v, got %v", test.query, test.want, got)
+			continue
+		}
+		for i, name := range test.want {
+			if got[i] != name {
+				t.Errorf("Expected %q to match %v first, got %v", test.query, test.want, got)
+				break
+			}
+		}
+	}
+
+	// The config's own order is left alone.
+	if conf.Roles[0].Name != "prod-readonly" {
+		t.Errorf("Expected the configured roles not to be reordered, got %v", names(conf.Roles))
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxRecentRoles is the number of recently used roles that we keep track of.
+const maxRecentRoles = 20
+
+// recentRolesFileName is the name of the file (in the state dir) that tracks recently used roles.
+const recentRolesFileName = "recent_roles"
+
+// recentRolesFilePath returns the path of the file that tracks recently used roles. It's more useful if it survives a
+// reboot, so it lives in the state dir rather than the cache dir (which may be a tmpfs).
+func recentRolesFilePath() string {
+	return filepath.Join(stateDir, recentRolesFileName)
+}
+
+// moveRecentRoles moves the recently used roles file from oldPath (where older versions of roo kept it, alongside the
+// config file) to newPath, unless there's already a file there.
+func moveRecentRoles(oldPath, newPath string) error {
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0700); err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// loadRecentRoles returns the ARNs of recently used roles, most recent first.
+func loadRecentRoles(filePath string) []string {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil
+	}
+	var arns []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			arns = append(arns, line)
+		}
+	}
+	return arns
+}
+
+// recordRecentRole moves (or adds) roleARN to the top of the recently used roles file.
+func recordRecentRole(filePath, roleARN string) error {
+	arns := []string{roleARN}
+	for _, arn := range loadRecentRoles(filePath) {
+		if arn != roleARN && len(arns) < maxRecentRoles {
+			arns = append(arns, arn)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(strings.Join(arns, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecentRoles(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state", recentRolesFileName)
+	if arns := loadRecentRoles(filePath); len(arns) != 0 {
+		t.Errorf("Expected no recent roles before any were recorded, got %v", arns)
+	}
+
+	for _, arn := range []string{"arn:a", "arn:b", "arn:c", "arn:a"} {
+		if err := recordRecentRole(filePath, arn); err != nil {
+			t.Fatalf("Unable to record %s: %s", arn, err)
+		}
+	}
+	if arns := strings.Join(loadRecentRoles(filePath), " "); arns != "arn:a arn:c arn:b" {
+		t.Errorf("Expected the most recent role first, without duplicates, got %s", arns)
+	}
+
+	for i := 0; i < maxRecentRoles+5; i++ {
+		if err := recordRecentRole(filePath, "arn:"+strings.Repeat("x", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if arns := loadRecentRoles(filePath); len(arns) != maxRecentRoles {
+		t.Errorf("Expected %d recent roles to be kept, got %d", maxRecentRoles, len(arns))
+	}
+}
+
+func TestMoveRecentRoles(t *testing.T) {
+	tempDir := t.TempDir()
+	oldPath := filepath.Join(tempDir, "config", recentRolesFileName)
+	newPath := filepath.Join(tempDir, "state", recentRolesFileName)
+
+	// Nothing to move.
+	if err := moveRecentRoles(oldPath, newPath); err != nil {
+		t.Errorf("Expected a missing file to be ignored, got %s", err)
+	}
+
+	if err := recordRecentRole(oldPath, "arn:old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveRecentRoles(oldPath, newPath); err != nil {
+		t.Fatalf("Unable to move the recent roles: %s", err)
+	}
+	if arns := loadRecentRoles(newPath); len(arns) != 1 || arns[0] != "arn:old" {
+		t.Errorf("Expected the recent roles to be moved, got %v", arns)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Error("Expected the old recent roles file to be removed")
+	}
+
+	// An existing file in the new location isn't overwritten.
+	if err := recordRecentRole(oldPath, "arn:stale"); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveRecentRoles(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if arns := loadRecentRoles(newPath); len(arns) != 1 || arns[0] != "arn:old" {
+		t.Errorf("Expected the existing recent roles to be kept, got %v", arns)
+	}
+}
@@ -0,0 +1,167 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// AccessKeyProviderName is the source of the credentials returned for access keys from a key store.
+const AccessKeyProviderName = "RooKeyStore"
+
+// KeyStoreOpener opens the store that long-term access keys are kept in for a key backend (See
+// cachedcredsprovider.NewKeyStore).
+type KeyStoreOpener func(backend string) (cachedcredsprovider.Store, error)
+
+// AccessKey returns the long-term access key stored for the named auth source (or the default auth source, if
+// sourceName is empty), or nil if one hasn't been stored. IssuedAt is when it was created (or stored, if IAM couldn't
+// say when it was created).
+func (c *Client) AccessKey(sourceName string) (*cachedcredsprovider.CachedCredentials, error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	return c.accessKey(source)
+}
+
+// accessKey returns the long-term access key stored for source, or nil if one hasn't been stored.
+func (c *Client) accessKey(source *config.AuthSource) (*cachedcredsprovider.CachedCredentials, error) {
+	store, err := c.keyStore(source)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := store.Get(source.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the access key for auth source '%s': %w", source.Name, err)
+	}
+	return stored, nil
+}
+
+// StoreAccessKey stores a long-term access key for the named auth source (or the default auth source, if sourceName
+// is empty) in its key backend, replacing any that's already stored. When the access key was created is looked up
+// with IAM, so that access_key_max_age applies to keys that were created long before they were stored - If that
+// fails, the key's age is counted from now.
+func (c *Client) StoreAccessKey(ctx context.Context, sourceName string, accessKeyID, secretAccessKey string) error {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return err
+	}
+	createdAt, err := c.accessKeyCreateDate(ctx, source, accessKeyID, secretAccessKey)
+	if err != nil {
+		slog.Warn(
+			"Unable to find out when the access key was created - Its age will be counted from now",
+			"auth_source", source.Name,
+			"access_key_id", accessKeyID,
+			"error", err,
+		)
+		createdAt = time.Now()
+	}
+	return c.putAccessKey(source, accessKeyID, secretAccessKey, createdAt)
+}
+
+// accessKeyCreateDate asks IAM (with the access key itself) when an access key for source's account was created.
+func (c *Client) accessKeyCreateDate(
+	ctx context.Context,
+	source *config.AuthSource,
+	accessKeyID, secretAccessKey string,
+) (time.Time, error) {
+	cfg, err := c.sourceConfig(ctx, source, awsconfig.WithCredentialsProvider(
+		credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+	))
+	if err != nil {
+		return time.Time{}, err
+	}
+	output, err := c.iamClient(cfg).ListAccessKeys(ctx, &iam.ListAccessKeysInput{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, key := range output.AccessKeyMetadata {
+		if aws.ToString(key.AccessKeyId) == accessKeyID {
+			return aws.ToTime(key.CreateDate), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s isn't one of the IAM user's access keys", accessKeyID)
+}
+
+// putAccessKey stores an access key for source, which was created at createdAt.
+func (c *Client) putAccessKey(
+	source *config.AuthSource,
+	accessKeyID, secretAccessKey string,
+	createdAt time.Time,
+) error {
+	store, err := c.keyStore(source)
+	if err != nil {
+		return err
+	}
+	stored := &cachedcredsprovider.CachedCredentials{
+		SourceProfile: source.Profile,
+		IssuedAt:      createdAt,
+		Values:        cachedcredsprovider.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey},
+	}
+	if err := store.Put(source.Name, stored); err != nil {
+		return fmt.Errorf("unable to store the access key for auth source '%s': %w", source.Name, err)
+	}
+	return nil
+}
+
+// DeleteAccessKey removes the long-term access key stored for the named auth source (or the default auth source, if
+// sourceName is empty). It's not an error if there isn't one.
+func (c *Client) DeleteAccessKey(sourceName string) error {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return err
+	}
+	store, err := c.keyStore(source)
+	if err != nil {
+		return err
+	}
+	return store.Delete(source.Name)
+}
+
+// usesStoredAccessKey reports whether source's credentials come from its key backend, rather than a profile.
+func (c *Client) usesStoredAccessKey(source *config.AuthSource) bool {
+	return c.Profile == "" && source.KeyBackend != ""
+}
+
+// keyStore returns the store that source's access key is kept in.
+func (c *Client) keyStore(source *config.AuthSource) (cachedcredsprovider.Store, error) {
+	if source.KeyBackend == "" {
+		return nil, fmt.Errorf("auth source '%s' doesn't have a key_backend", source.Name)
+	}
+	if c.OpenKeyStore == nil {
+		return nil, errors.New("access keys can't be stored, as no key store is available")
+	}
+	return c.OpenKeyStore(source.KeyBackend)
+}
+
+// accessKeyProvider returns a credentials provider for the access key stored for source.
+func (c *Client) accessKeyProvider(source *config.AuthSource) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		stored, err := c.accessKey(source)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		if stored == nil {
+			return aws.Credentials{}, fmt.Errorf(
+				"no access key is stored for auth source '%s' - Run 'roo auth add %s' to add one",
+				source.Name, source.Name,
+			)
+		}
+		warnAboutAccessKeyAge(source, stored.Values.AccessKeyID, stored.IssuedAt)
+		return aws.Credentials{
+			AccessKeyID:     stored.Values.AccessKeyID,
+			SecretAccessKey: stored.Values.SecretAccessKey,
+			Source:          AccessKeyProviderName,
+		}, nil
+	}))
+}
@@ -0,0 +1,100 @@
+package roo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+)
+
+func TestAssumeRoleWithStoredAccessKey(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "AKIASTORED" {
+			t.Errorf("Expected the MFA session to be requested with the stored access key, got %s", accessKeyID(r))
+		}
+		return sessionTokenResult("ASIASESSION"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+	conf := authSourceConfig()
+	conf.AuthSources[0].KeyBackend = "test"
+	conf.AuthSources[0].MFASerials = conf.AuthSources[0].MFASerials[:1]
+	client := New(conf, memoryCache{}, codes("111111"))
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+	store := cachedcredsprovider.NewMemoryStore()
+	client.OpenKeyStore = func(backend string) (cachedcredsprovider.Store, error) {
+		if backend != "test" {
+			t.Errorf("Unexpected key backend: %s", backend)
+		}
+		return store, nil
+	}
+
+	_, err := client.BaseCredentials(context.Background(), "corporate")
+	if err == nil || !strings.Contains(err.Error(), "roo auth add corporate") {
+		t.Errorf("Expected an error explaining how to add an access key, got %v", err)
+	}
+
+	// The key's age is counted from when it was created, rather than when it was stored.
+	fake.actions["ListAccessKeys"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "AKIASTORED" {
+			t.Errorf("Expected the access keys to be listed with the new access key, got %s", accessKeyID(r))
+		}
+		return listAccessKeysResult("AKIASTORED", "2020-01-02T03:04:05Z"), "", ""
+	}
+	if err := client.StoreAccessKey(context.Background(), "corporate", "AKIASTORED", "secret"); err != nil {
+		t.Fatalf("Unable to store the access key: %s", err)
+	}
+	stored, err := client.AccessKey("corporate")
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err != nil || stored == nil || stored.Values.AccessKeyID != "AKIASTORED" || !stored.IssuedAt.Equal(createdAt) {
+		t.Fatalf("Unexpected stored access key: %+v (error: %v)", stored, err)
+	}
+	creds, err := client.BaseCredentials(context.Background(), "corporate")
+	if err != nil {
+		t.Fatalf("Unable to get the base credentials: %s", err)
+	}
+	if creds.AccessKeyID != "AKIASTORED" || creds.Source != AccessKeyProviderName {
+		t.Errorf("Expected the stored access key to be used instead of the environment, got %+v", creds)
+	}
+	if _, err := client.Credentials(context.Background(), "prod-readonly"); err != nil {
+		t.Fatalf("Unable to assume a role with the stored access key: %s", err)
+	}
+
+	if err := client.DeleteAccessKey("corporate"); err != nil {
+		t.Fatalf("Unable to delete the access key: %s", err)
+	}
+	if stored, err := client.AccessKey("corporate"); err != nil || stored != nil {
+		t.Errorf("Expected the access key to be deleted, got %+v (error: %v)", stored, err)
+	}
+}
+
+func TestStoreAccessKeyWithoutCreateDate(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["ListAccessKeys"] = func(r *http.Request) (string, string, string) {
+		return "", "AccessDenied", "User is not authorized to perform: iam:ListAccessKeys"
+	}
+	conf := authSourceConfig()
+	conf.AuthSources[0].KeyBackend = "test"
+	client := New(conf, memoryCache{}, nil)
+	client.IAMEndpoint = server.URL
+	store := cachedcredsprovider.NewMemoryStore()
+	client.OpenKeyStore = func(backend string) (cachedcredsprovider.Store, error) {
+		return store, nil
+	}
+
+	// If IAM won't say when the key was created, its age is counted from now.
+	before := time.Now()
+	if err := client.StoreAccessKey(context.Background(), "corporate", "AKIASTORED", "secret"); err != nil {
+		t.Fatalf("Unable to store the access key: %s", err)
+	}
+	stored, err := client.AccessKey("corporate")
+	if err != nil || stored == nil || stored.IssuedAt.Before(before) {
+		t.Errorf("Expected the access key to be stored as created now, got %+v (error: %v)", stored, err)
+	}
+}
@@ -0,0 +1,379 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// invalidSessionErrorCodes are the STS error codes returned when assuming a role with an MFA session that's no longer
+// valid - e.g. because it was revoked.
+var invalidSessionErrorCodes = map[string]bool{
+	"ExpiredToken":         true,
+	"InvalidClientTokenId": true,
+}
+
+// SessionCacheKey returns the key that the session for source (an MFA session, or a web identity source's session) is
+// cached under: session_{{.Name}}, followed by @{{.Profile}} if the session is started from a profile (which may have
+// been overridden with Client.Profile), so that sessions for different profiles don't get mixed up. The profile is
+// escaped, as the key may be used as a file name. See IsSessionCacheKey.
+func (c *Client) SessionCacheKey(source *config.AuthSource) string {
+	key := sessionCacheKeyPrefix + source.Name
+	if profile := c.profile(source); profile != "" {
+		key += "@" + url.QueryEscape(profile)
+	}
+	return key
+}
+
+// MFASession returns the MFA session for the named auth source (or the default auth source, if sourceName is empty),
+// getting a new one with an MFA code if the cached one has expired. refreshed is true if it's a new session.
+func (c *Client) MFASession(
+	ctx context.Context,
+	sourceName string,
+) (cached *cachedcredsprovider.CachedCredentials, refreshed bool, err error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return nil, false, err
+	}
+	if !source.MFASession && !source.IsWebIdentity() {
+		return nil, false, fmt.Errorf("auth source '%s' doesn't use an MFA session", source.Name)
+	}
+	return c.sessionProvider(source).Get(ctx)
+}
+
+// authSource returns the auth source with the given name, or the default auth source (or Client.AuthSource) if name
+// is empty.
+func (c *Client) authSource(name string) (*config.AuthSource, error) {
+	if name == "" {
+		name = c.AuthSource
+	}
+	if name == "" {
+		return c.Config.GetAuthSource(nil)
+	}
+	if source := c.Config.GetNamedAuthSource(name); source != nil {
+		return source, nil
+	}
+	return nil, fmt.Errorf("auth source '%s' isn't configured", name)
+}
+
+// roleAuthSource returns the auth source that role is assumed from - Client.AuthSource if it's set, otherwise the one
+// that the config says.
+func (c *Client) roleAuthSource(role *config.RoleConfig) (*config.AuthSource, error) {
+	if c.AuthSource != "" {
+		return c.authSource(c.AuthSource)
+	}
+	return c.Config.GetAuthSource(role)
+}
+
+// assumeRoleWithMFA assumes role from its auth source. If the auth source uses an MFA session, the role is assumed
+// with that (getting a new one first if needed), otherwise it's assumed with a code from the MFA provider.
+func (c *Client) assumeRoleWithMFA(
+	ctx context.Context,
+	role *config.RoleConfig,
+	refreshMFASession bool,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	source, err := c.roleAuthSource(role)
+	if err != nil {
+		return nil, err
+	}
+	if source.IsWebIdentity() && role.ARN == webIdentityRoleARN(source) {
+		// The web identity token is exchanged for the role itself, so there's nothing more to assume.
+		return c.webIdentityRoleCredentials(ctx, source, refreshMFASession)
+	}
+	if source.MFASession || source.IsWebIdentity() {
+		return c.assumeRoleWithMFASession(ctx, role, source, refreshMFASession)
+	}
+
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	stsClient := c.stsClient(cfg)
+	var assumeRoleOutput *sts.AssumeRoleOutput
+	sourceIdentity, err := c.withMFACode(ctx, stsClient, source, func(serial, code string) error {
+		var err error
+		assumeRoleOutput, err = c.assumeRole(ctx, stsClient, role, serial, code)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	cached := cachedcredsprovider.NewCachedCredentialsFromSTS(assumeRoleOutput, sourceIdentity)
+	cached.RoleARN = role.ARN
+	cached.SourceProfile = c.profile(source)
+	if cacheKey, err := CacheKey(role); err == nil {
+		c.recordProfileAccessKey(ctx, source, cfg, cached, cacheKey)
+	}
+	return cached, nil
+}
+
+// assumeRoleWithMFASession assumes role with the MFA session for source. If AWS rejects the MFA session, a new one is
+// started and the role assumed again.
+func (c *Client) assumeRoleWithMFASession(
+	ctx context.Context,
+	role *config.RoleConfig,
+	source *config.AuthSource,
+	refreshMFASession bool,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	session := c.sessionProvider(source)
+	if refreshMFASession {
+		session.Expire()
+	}
+	for attempt := 1; ; attempt++ {
+		sessionCredentials, _, err := session.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := c.sourceConfig(ctx, source, awsconfig.WithCredentialsProvider(session))
+		if err != nil {
+			return nil, err
+		}
+		assumeRoleOutput, err := c.assumeRole(ctx, c.stsClient(cfg), role, "", "")
+		if err == nil {
+			cached := cachedcredsprovider.NewCachedCredentialsFromSTS(assumeRoleOutput, sessionCredentials.SourceIdentity)
+			cached.RoleARN = role.ARN
+			cached.SourceProfile = c.profile(source)
+			cached.SourceAccessKeyID = sessionCredentials.SourceAccessKeyID
+			cached.SourceAccessKeyCreatedAt = sessionCredentials.SourceAccessKeyCreatedAt
+			return cached, nil
+		}
+		var apiErr smithy.APIError
+		if attempt > 1 || !errors.As(err, &apiErr) || !invalidSessionErrorCodes[apiErr.ErrorCode()] {
+			return nil, c.withClockSkew(ctx, err, 0, false)
+		}
+		slog.Warn("AWS rejected the MFA session - Starting a new one", "auth_source", source.Name, "error", err)
+		session.Expire()
+	}
+}
+
+// sessionProvider returns a credentials provider for the session that roles are assumed from source with, which gets
+// a new session whenever the cached one has expired - An MFA session (with a code from the MFA provider), or for
+// web_identity sources, a session for the source's role (with the web identity token).
+func (c *Client) sessionProvider(source *config.AuthSource) *cachedcredsprovider.CachedCredProvider {
+	refresh := func(ctx context.Context) (*cachedcredsprovider.CachedCredentials, error) {
+		if source.IsWebIdentity() {
+			return c.getWebIdentitySession(ctx, source)
+		}
+		return c.getMFASession(ctx, source)
+	}
+	return cachedcredsprovider.New(c.Cache, c.SessionCacheKey(source), refresh)
+}
+
+// getMFASession gets a new MFA session for source with GetSessionToken.
+func (c *Client) getMFASession(
+	ctx context.Context,
+	source *config.AuthSource,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	stsClient := c.stsClient(cfg)
+	var sessionTokenOutput *sts.GetSessionTokenOutput
+	sourceIdentity, err := c.withMFACode(ctx, stsClient, source, func(serial, code string) error {
+		input := &sts.GetSessionTokenInput{
+			DurationSeconds: aws.Int32(int32(source.GetMFASessionDuration().Seconds())),
+		}
+		if serial != "" {
+			input.SerialNumber = aws.String(serial)
+			input.TokenCode = aws.String(code)
+		}
+		var err error
+		sessionTokenOutput, err = stsClient.GetSessionToken(ctx, input)
+		if err != nil {
+			return fmt.Errorf("unable to get an MFA session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Started a new MFA session", "auth_source", source.Name,
+		"expires_at", aws.ToTime(sessionTokenOutput.Credentials.Expiration))
+	cached := cachedcredsprovider.NewCachedCredentialsFromSessionToken(sessionTokenOutput, sourceIdentity)
+	cached.SessionARN = sourceIdentity
+	cached.SourceProfile = c.profile(source)
+	c.recordProfileAccessKey(ctx, source, cfg, cached, c.SessionCacheKey(source))
+	return cached, nil
+}
+
+// withMFACode calls submit with a code from the MFA provider, and the serial of the MFA device it's for. If AWS (or
+// the MFA provider) rejects the code, the MFA provider is asked for another one, up to Config.MFAAttempts times. If
+// source doesn't have any MFA devices, submit is called once with an empty serial and code.
+//
+// The ARN of the identity that the code was submitted as is returned.
+func (c *Client) withMFACode(
+	ctx context.Context,
+	stsClient *sts.Client,
+	source *config.AuthSource,
+	submit func(serial, code string) error,
+) (string, error) {
+	devices := c.mfaDevices(source)
+	if len(devices) > 0 && c.MFA == nil && source.MFACodeSource != config.MFACodeSourceTOTP {
+		return "", ErrMFARequired
+	}
+
+	callerIdentityOutput, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", c.withClockSkew(ctx, fmt.Errorf("unable to get caller identity: %w", err), 0, false)
+	}
+	callerARN := aws.ToString(callerIdentityOutput.Arn)
+	// Check the clock before prompting, as MFA codes won't be accepted if it's too far out.
+	skew, skewKnown := c.observedClockSkew(ctx, callerIdentityOutput.ResultMetadata)
+	if skewKnown {
+		c.warnAboutClockSkew(skew)
+	}
+	if len(devices) == 0 {
+		// There's nothing to prompt for - AWS will reject the request if it needs MFA.
+		return callerARN, c.withClockSkew(ctx, submit("", ""), skew, skewKnown)
+	}
+
+	mfaSerial, err := c.selectMFADevice(ctx, source, devices)
+	if err != nil {
+		return "", err
+	}
+	if err := c.waitForFreshCode(ctx, mfaSerial); err != nil {
+		return "", err
+	}
+	maxAttempts := c.Config.GetMFAAttempts()
+	var rejectedCodes []string
+	for attempt := 1; ; attempt++ {
+		oneTimePasscode, err := c.mfaCode(ctx, source, mfaSerial)
+		if errors.Is(err, ErrMFACodeRejected) && attempt < maxAttempts {
+			slog.Warn("Invalid MFA code - Please try again", "error", err, "attempt", attempt,
+				"max_attempts", maxAttempts)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		// There's no point submitting a code that's already been rejected (e.g. one passed on the command line).
+		for _, rejectedCode := range rejectedCodes {
+			if oneTimePasscode == rejectedCode {
+				err := fmt.Errorf("%w: the same code was provided again", ErrMFACodeRejected)
+				return "", c.withClockSkew(ctx, err, skew, skewKnown)
+			}
+		}
+
+		err = c.submitMFACode(ctx, mfaSerial, oneTimePasscode, submit)
+		if err == nil {
+			return callerARN, nil
+		}
+		if !errors.Is(err, ErrMFACodeRejected) || attempt >= maxAttempts {
+			return "", c.withClockSkew(ctx, err, skew, skewKnown)
+		}
+		slog.Warn("AWS rejected the MFA code - Please try again", "attempt", attempt, "max_attempts", maxAttempts)
+		rejectedCodes = append(rejectedCodes, oneTimePasscode)
+	}
+}
+
+// submitMFACode calls submit with an MFA code, recording the code as used. If the code has already been used, it
+// waits for the next code rather than submitting it, and returns an error wrapping ErrMFACodeRejected - As it does if
+// AWS rejects the code.
+func (c *Client) submitMFACode(
+	ctx context.Context,
+	mfaSerial, oneTimePasscode string,
+	submit func(serial, code string) error,
+) error {
+	if c.UsedCodes != nil {
+		usedAt, reused, err := c.UsedCodes.lastUsed(mfaSerial, oneTimePasscode)
+		if err != nil {
+			slog.Debug("Unable to check whether the MFA code has been used", "error", err)
+		} else if reused && time.Since(usedAt) < 2*totpPeriod {
+			slog.Warn("That MFA code has already been used - Waiting for the next one")
+			if err := waitUntil(ctx, nextCodeAt(time.Now())); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: it has already been used", ErrMFACodeRejected)
+		}
+	}
+
+	err := submit(mfaSerial, oneTimePasscode)
+	if rejectedErr := mfaRejectedError(err); rejectedErr != nil {
+		return rejectedErr
+	} else if err != nil {
+		return err
+	}
+
+	if c.UsedCodes != nil {
+		if err := c.UsedCodes.Record(mfaSerial, oneTimePasscode, time.Now()); err != nil {
+			slog.Warn("Unable to record the MFA code as used", "error", err)
+		}
+	}
+	return nil
+}
+
+// assumeRole assumes role, with an MFA code if mfaSerial is set.
+func (c *Client) assumeRole(
+	ctx context.Context,
+	stsClient *sts.Client,
+	role *config.RoleConfig,
+	mfaSerial, oneTimePasscode string,
+) (*sts.AssumeRoleOutput, error) {
+	timeNowUnixNanoString := strconv.FormatInt(time.Now().UnixNano(), 10)
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(role.ARN),
+		RoleSessionName: aws.String("roo-" + timeNowUnixNanoString),
+	}
+	if mfaSerial != "" {
+		input.SerialNumber = aws.String(mfaSerial)
+		input.TokenCode = aws.String(oneTimePasscode)
+	}
+	assumeRoleOutput, err := stsClient.AssumeRole(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to assume role %s: %w", role.ARN, err)
+	}
+	return assumeRoleOutput, nil
+}
+
+// mfaDevices returns the serials of the MFA devices that can be used with source - Just MFASerial, if it's set.
+func (c *Client) mfaDevices(source *config.AuthSource) []string {
+	if c.MFASerial != "" {
+		return []string{c.MFASerial}
+	}
+	return source.MFASerials
+}
+
+// selectMFADevice returns the MFA device to use from devices, asking the MFA device selector if there's more than one.
+func (c *Client) selectMFADevice(ctx context.Context, source *config.AuthSource, devices []string) (string, error) {
+	if len(devices) == 1 || c.MFADeviceSelector == nil {
+		return devices[0], nil
+	}
+	serial, err := c.MFADeviceSelector.SelectMFADevice(ctx, source.Name, devices)
+	if err != nil {
+		return "", fmt.Errorf("unable to select an MFA device: %w", err)
+	}
+	return serial, nil
+}
+
+// sourceConfig loads the AWS SDK config for source, with its profile and region.
+func (c *Client) sourceConfig(
+	ctx context.Context,
+	source *config.AuthSource,
+	optFns ...func(*awsconfig.LoadOptions) error,
+) (aws.Config, error) {
+	sourceOptFns := []func(*awsconfig.LoadOptions) error{awsconfig.WithSharedConfigProfile(c.profile(source))}
+	if c.usesStoredAccessKey(source) {
+		sourceOptFns = append(sourceOptFns, awsconfig.WithCredentialsProvider(c.accessKeyProvider(source)))
+	}
+	optFns = append(sourceOptFns, optFns...)
+	if source.Region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(source.Region))
+	}
+	cfg, err := c.awsConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load the AWS config for the authentication account: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,145 @@
+package roo
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jkueh/roo/config"
+)
+
+// sessionTokenResult returns the GetSessionToken result for a successful call.
+func sessionTokenResult(accessKeyID string) string {
+	return "<Credentials><AccessKeyId>" + accessKeyID + "</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>" +
+		"<SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>"
+}
+
+// accessKeyID returns the access key ID that r was signed with.
+func accessKeyID(r *http.Request) string {
+	_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	keyID, _, _ := strings.Cut(credential, "/")
+	return keyID
+}
+
+func authSourceConfig() *config.Config {
+	return &config.Config{
+		AuthSources: []config.AuthSource{{
+			Name:       "corporate",
+			Default:    true,
+			MFASerials: []string{"arn:aws:iam::000000000000:mfa/primary", "arn:aws:iam::000000000000:mfa/backup"},
+			MFASession: true,
+		}},
+		Roles: []config.RoleConfig{
+			{Name: "prod-readonly", ARN: "arn:aws:iam::111111111111:role/ReadOnly"},
+			{Name: "test-developer", ARN: "arn:aws:iam::222222222222:role/Developer"},
+		},
+	}
+}
+
+func TestAssumeRoleWithMFASession(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		if r.Form.Get("SerialNumber") != "arn:aws:iam::000000000000:mfa/backup" || r.Form.Get("TokenCode") != "111111" {
+			t.Errorf("Unexpected MFA device and code: %s, %s", r.Form.Get("SerialNumber"), r.Form.Get("TokenCode"))
+		}
+		return sessionTokenResult("ASIASESSION"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		if r.Form.Get("TokenCode") != "" || accessKeyID(r) != "ASIASESSION" {
+			t.Errorf("Expected the role to be assumed with the MFA session, got %s", accessKeyID(r))
+		}
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+	client := New(authSourceConfig(), memoryCache{}, codes("111111"))
+	client.STSEndpoint = server.URL
+	client.UsedCodes = NewUsedCodes(filepath.Join(t.TempDir(), "used_codes.json"))
+	client.MFADeviceSelector = MFADeviceSelectorFunc(
+		func(ctx context.Context, source string, serials []string) (string, error) {
+			if source != "corporate" || len(serials) != 2 {
+				t.Errorf("Unexpected MFA devices for %s: %v", source, serials)
+			}
+			return serials[1], nil
+		},
+	)
+
+	// Both roles are assumed with the one MFA session, so the MFA code is only needed once.
+	for _, role := range []string{"prod-readonly", "test-developer"} {
+		creds, err := client.Credentials(context.Background(), role)
+		if err != nil {
+			t.Fatalf("Unable to get credentials for %s: %s", role, err)
+		}
+		if creds.AccessKeyID != "ASIAROLE" {
+			t.Errorf("Unexpected access key ID: %s", creds.AccessKeyID)
+		}
+	}
+	if fake.calls["GetSessionToken"] != 1 || fake.calls["AssumeRole"] != 2 {
+		t.Errorf("Expected 1 MFA session for 2 roles, got %d and %d",
+			fake.calls["GetSessionToken"], fake.calls["AssumeRole"])
+	}
+	if _, ok := client.Cache.(memoryCache)["session_corporate"]; !ok {
+		t.Error("Expected the MFA session to be cached")
+	}
+}
+
+func TestAssumeRoleWithRevokedMFASession(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		if fake.calls["GetSessionToken"] == 1 {
+			return sessionTokenResult("ASIAREVOKED"), "", ""
+		}
+		return sessionTokenResult("ASIANEWSESSION"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "ASIANEWSESSION" {
+			return "", "ExpiredToken", "The security token included in the request is expired"
+		}
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+	conf := authSourceConfig()
+	conf.AuthSources[0].MFASerials = conf.AuthSources[0].MFASerials[:1]
+	client := New(conf, memoryCache{}, codes("111111", "222222"))
+	client.STSEndpoint = server.URL
+
+	creds, err := client.Credentials(context.Background(), "prod-readonly")
+	if err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if creds.AccessKeyID != "ASIAROLE" || fake.calls["GetSessionToken"] != 2 {
+		t.Errorf("Expected a new MFA session, got %s after %d sessions", creds.AccessKeyID, fake.calls["GetSessionToken"])
+	}
+}
+
+func TestSessionCacheKey(t *testing.T) {
+	client := New(authSourceConfig(), memoryCache{}, nil)
+	source := &config.AuthSource{Name: "corporate"}
+	tests := []struct {
+		sourceProfile   string
+		overrideProfile string
+		want            string
+	}{
+		{"", "", "session_corporate"},
+		{"base", "", "session_corporate@base"},
+		// A -profile override gets a session of its own.
+		{"base", "other", "session_corporate@other"},
+		// Profiles are escaped rather than mangled, so that different profiles never share a session.
+		{"", "team/admin", "session_corporate@team%2Fadmin"},
+		{"", "team-admin", "session_corporate@team-admin"},
+	}
+	for _, test := range tests {
+		source.Profile, client.Profile = test.sourceProfile, test.overrideProfile
+		key := client.SessionCacheKey(source)
+		if key != test.want {
+			t.Errorf("Expected %s, got %s", test.want, key)
+		}
+		if !IsSessionCacheKey(key) {
+			t.Errorf("Expected %s to be recognised as a session's cache key", key)
+		}
+	}
+
+	roleKey, _ := CacheKey(&authSourceConfig().Roles[0])
+	if IsSessionCacheKey(roleKey) {
+		t.Errorf("Expected the role's cache key, %s, not to be a session's", roleKey)
+	}
+}
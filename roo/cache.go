@@ -0,0 +1,39 @@
+package roo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// Cache stores credentials between calls, keyed by role (See CacheKey).
+type Cache = cachedcredsprovider.Cache
+
+// CacheKey returns the key that credentials for role are cached under: {{.AccountNumber}}-{{.RoleName}}
+func CacheKey(role *config.RoleConfig) (string, error) {
+	if role.AccountID() == "" {
+		return "", fmt.Errorf("unable to determine account number from ARN: %s", role.ARN)
+	}
+	return fmt.Sprintf("%s-%s", role.AccountID(), role.RoleName()), nil
+}
+
+// sessionCacheKeyPrefix starts the keys that auth source sessions (MFA or web identity) are cached under (See
+// Client.SessionCacheKey). It can't be mistaken for a role's cache key, as those start with an account ID.
+const sessionCacheKeyPrefix = "session_"
+
+// IsSessionCacheKey returns true if key is one that an auth source's session (rather than a role's credentials) is
+// cached under, so that sessions can be left out when listing the cached roles.
+func IsSessionCacheKey(key string) bool {
+	return strings.HasPrefix(key, sessionCacheKeyPrefix)
+}
+
+// FileCache caches credentials as files in a directory - The format used by the roo CLI. Other stores (e.g. the
+// kernel keyring) can be found in the cachedcredsprovider package, and all of them implement Cache.
+type FileCache = cachedcredsprovider.FileStore
+
+// NewFileCache returns a FileCache that stores files in dir, which must already exist.
+func NewFileCache(dir string) *FileCache {
+	return cachedcredsprovider.NewFileStore(dir)
+}
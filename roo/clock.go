@@ -0,0 +1,136 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// defaultTimeSource is the URL whose Date header is used to measure clock skew, if there isn't a better one.
+const defaultTimeSource = "https://sts.amazonaws.com/"
+
+// DefaultClockSkewThreshold is how far the local clock can drift from AWS before roo warns about it. MFA codes
+// change every 30 seconds, so much more than that and they start getting rejected.
+const DefaultClockSkewThreshold = 30 * time.Second
+
+// clockSensitiveErrorCodes are the STS error codes that can be caused by the local clock being wrong.
+var clockSensitiveErrorCodes = map[string]bool{
+	"SignatureDoesNotMatch":     true,
+	"InvalidSignatureException": true,
+	"RequestExpired":            true,
+}
+
+// ClockSkew returns how far ahead of AWS the local clock is (negative if it's behind), using the Date header of a
+// HEAD request to TimeSource - or to the STS endpoint, if that isn't set. The Date header only has a resolution of a
+// second, so neither is the result.
+func (c *Client) ClockSkew(ctx context.Context) (time.Duration, error) {
+	timeSource := c.TimeSource
+	if timeSource == "" {
+		timeSource = defaultTimeSource
+		if c.STSEndpoint != "" {
+			timeSource = c.STSEndpoint
+		}
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, timeSource, nil)
+	if err != nil {
+		return 0, err
+	}
+	sentAt := time.Now()
+	resp, err := c.httpClient().Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get the time from %s: %w", timeSource, err)
+	}
+	resp.Body.Close()
+	receivedAt := time.Now()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("%s didn't return a valid Date header: %w", timeSource, err)
+	}
+	// Assume the server generated the header half way through the round trip.
+	localTime := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	return localTime.Sub(serverTime).Round(time.Second), nil
+}
+
+// DescribeClockSkew describes skew (as returned by ClockSkew) for humans.
+func DescribeClockSkew(skew time.Duration) string {
+	switch {
+	case skew > 0:
+		return fmt.Sprintf("local clock is %s ahead of AWS", skew)
+	case skew < 0:
+		return fmt.Sprintf("local clock is %s behind AWS", -skew)
+	default:
+		return "local clock is in sync with AWS"
+	}
+}
+
+// observedClockSkew returns the clock skew for an STS response, using the response's Date header (or TimeSource, if
+// it's set). ok is false if it couldn't be determined.
+func (c *Client) observedClockSkew(ctx context.Context, metadata middleware.Metadata) (skew time.Duration, ok bool) {
+	if c.TimeSource == "" {
+		serverTime, serverTimeOK := awsmiddleware.GetServerTime(metadata)
+		responseAt, responseAtOK := awsmiddleware.GetResponseAt(metadata)
+		if serverTimeOK && responseAtOK {
+			return responseAt.Sub(serverTime).Round(time.Second), true
+		}
+	}
+	skew, err := c.ClockSkew(ctx)
+	if err != nil {
+		slog.Debug("Unable to measure clock skew", "error", err)
+		return 0, false
+	}
+	return skew, true
+}
+
+// isClockSkewed reports whether skew is beyond the threshold.
+func (c *Client) isClockSkewed(skew time.Duration) bool {
+	threshold := c.ClockSkewThreshold
+	if threshold == 0 {
+		threshold = DefaultClockSkewThreshold
+	}
+	return skew > threshold || skew < -threshold
+}
+
+// warnAboutClockSkew logs a warning if skew is beyond the threshold.
+func (c *Client) warnAboutClockSkew(skew time.Duration) {
+	if c.isClockSkewed(skew) {
+		slog.Warn("Your clock is out of sync with AWS - MFA codes and request signatures may be rejected until it's "+
+			"fixed", "skew", DescribeClockSkew(skew))
+	}
+}
+
+// withClockSkew adds the clock skew to err, if err could have been caused by the local clock being wrong and the skew
+// is beyond the threshold. If the skew isn't known, it's only measured for signature errors - Rejected MFA codes are
+// far more often mistyped than caused by the clock, so they're not worth another request.
+func (c *Client) withClockSkew(ctx context.Context, err error, skew time.Duration, skewKnown bool) error {
+	if !skewKnown {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || !clockSensitiveErrorCodes[apiErr.ErrorCode()] {
+			return err
+		}
+		var skewErr error
+		if skew, skewErr = c.ClockSkew(ctx); skewErr != nil {
+			return err
+		}
+	}
+	if !isClockSensitiveError(err) || !c.isClockSkewed(skew) {
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, DescribeClockSkew(skew))
+}
+
+// isClockSensitiveError reports whether err could have been caused by the local clock being wrong.
+func isClockSensitiveError(err error) bool {
+	if errors.Is(err, ErrMFACodeRejected) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && clockSensitiveErrorCodes[apiErr.ErrorCode()]
+}
@@ -0,0 +1,86 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// skewedServer returns a server whose Date header is offset from the local clock by -skew.
+func skewedServer(t *testing.T, skew time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-skew).UTC().Format(http.TimeFormat))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClockSkew(t *testing.T) {
+	for _, expected := range []time.Duration{0, 2 * time.Minute, -time.Hour} {
+		client := New(testConfig(), nil, nil)
+		client.TimeSource = skewedServer(t, expected).URL
+
+		skew, err := client.ClockSkew(context.Background())
+		if err != nil {
+			t.Fatalf("Unable to measure clock skew: %s", err)
+		}
+		if difference := skew - expected; difference < -2*time.Second || difference > 2*time.Second {
+			t.Errorf("Expected a skew of about %s, got %s", expected, skew)
+		}
+	}
+}
+
+func TestDescribeClockSkew(t *testing.T) {
+	for skew, expected := range map[time.Duration]string{
+		45 * time.Second: "local clock is 45s ahead of AWS",
+		-2 * time.Minute: "local clock is 2m0s behind AWS",
+		0:                "local clock is in sync with AWS",
+	} {
+		if description := DescribeClockSkew(skew); description != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, description)
+		}
+	}
+}
+
+func TestRejectedMFACodeErrorIncludesClockSkew(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return "", "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."
+	}
+	conf := testConfig()
+	conf.MFAAttempts = 1
+	client := New(conf, memoryCache{}, StaticMFACode("111111"))
+	client.STSEndpoint = server.URL
+	fake.skew = 5 * time.Minute
+
+	_, err := client.Credentials(context.Background(), "prod-readonly")
+	if !errors.Is(err, ErrMFACodeRejected) {
+		t.Fatalf("Expected ErrMFACodeRejected, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "ahead of AWS") {
+		t.Errorf("Expected the error to include the clock skew, got: %s", err)
+	}
+}
+
+func TestRejectedMFACodeErrorOmitsSmallClockSkew(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return "", "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."
+	}
+	conf := testConfig()
+	conf.MFAAttempts = 1
+	client := New(conf, memoryCache{}, StaticMFACode("111111"))
+	client.STSEndpoint = server.URL
+
+	_, err := client.Credentials(context.Background(), "prod-readonly")
+	if !errors.Is(err, ErrMFACodeRejected) {
+		t.Fatalf("Expected ErrMFACodeRejected, got %v", err)
+	}
+	if strings.Contains(err.Error(), "AWS)") {
+		t.Errorf("Expected the clock not to be mentioned when it's in sync, got: %s", err)
+	}
+}
@@ -0,0 +1,117 @@
+package roo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// federationEndpoint is the AWS endpoint that exchanges credentials for console sign-in tokens.
+const federationEndpoint = "https://signin.aws.amazon.com/federation"
+
+// SigninTokenResponse - The payload that comes back from https://signin.aws.amazon.com/federation
+type SigninTokenResponse struct {
+	SigninToken string `json:"SigninToken"`
+}
+
+// ConsoleURLOptions customise the console sign-in URL.
+type ConsoleURLOptions struct {
+	// Destination is the console URL to land on after signing in. Defaults to https://console.aws.amazon.com/
+	Destination string
+	// Issuer identifies the tool that issued the sign-in URL. Defaults to "roo".
+	Issuer string
+	// SessionDuration is how long the console session lasts. Defaults to 12 hours.
+	SessionDuration time.Duration
+}
+
+// ConsoleURL returns a URL that signs in to the AWS console as the role matching roleRef.
+func (c *Client) ConsoleURL(ctx context.Context, roleRef string, opts ConsoleURLOptions) (string, error) {
+	creds, err := c.Credentials(ctx, roleRef)
+	if err != nil {
+		return "", err
+	}
+	return c.SessionConsoleURL(ctx, creds, opts)
+}
+
+// SessionConsoleURL returns a URL that signs in to the AWS console with creds.
+func (c *Client) SessionConsoleURL(
+	ctx context.Context,
+	creds aws.Credentials,
+	opts ConsoleURLOptions,
+) (string, error) {
+	if opts.Destination == "" {
+		opts.Destination = "https://console.aws.amazon.com/"
+	}
+	if opts.Issuer == "" {
+		opts.Issuer = "roo"
+	}
+	if opts.SessionDuration == 0 {
+		opts.SessionDuration = 12 * time.Hour
+	}
+
+	signinToken, err := c.signinToken(ctx, creds, opts.SessionDuration)
+	if err != nil {
+		return "", err
+	}
+
+	consoleURLRequest, err := http.NewRequest(http.MethodGet, federationEndpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to construct console sign-in URL: %w", err)
+	}
+	consoleURLRequestQuery := consoleURLRequest.URL.Query()
+	consoleURLRequestQuery.Add("Action", "login")
+	consoleURLRequestQuery.Add("Issuer", opts.Issuer)
+	consoleURLRequestQuery.Add("Destination", opts.Destination)
+	consoleURLRequestQuery.Add("SigninToken", signinToken)
+	consoleURLRequest.URL.RawQuery = consoleURLRequestQuery.Encode()
+
+	return consoleURLRequest.URL.String(), nil
+}
+
+// signinToken exchanges creds for a console sign-in token at the federation endpoint.
+func (c *Client) signinToken(ctx context.Context, creds aws.Credentials, duration time.Duration) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, federationEndpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to construct request to federation endpoint: %w", err)
+	}
+	sessionData := map[string]string{
+		"sessionId":    creds.AccessKeyID,
+		"sessionKey":   creds.SecretAccessKey,
+		"sessionToken": creds.SessionToken,
+	}
+	sessionDataJSON, err := json.Marshal(&sessionData)
+	if err != nil {
+		return "", fmt.Errorf("unable to build session credentials for federation endpoint call: %w", err)
+	}
+	query := request.URL.Query()
+	query.Add("Action", "getSigninToken")
+	query.Add("SessionDuration", strconv.Itoa(int(duration.Seconds())))
+	query.Add("Session", string(sessionDataJSON))
+	request.URL.RawQuery = query.Encode()
+
+	resp, err := c.httpClient().Do(request)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve data from federation endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the response body from the federation endpoint: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("federation endpoint returned %s", resp.Status)
+	}
+
+	var signInTokenResponse SigninTokenResponse
+	if err := json.Unmarshal(respBody, &signInTokenResponse); err != nil {
+		return "", fmt.Errorf("unable to unmarshal sign-in token response: %w", err)
+	}
+	return signInTokenResponse.SigninToken, nil
+}
@@ -0,0 +1,45 @@
+package roo
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMFARequired is returned when credentials need to be refreshed, but the Client doesn't have an MFA provider.
+var ErrMFARequired = errors.New("an MFA code is required to refresh credentials")
+
+// MFAProvider provides one-time passcodes for an MFA device.
+type MFAProvider interface {
+	// MFACode returns the current one-time passcode for the MFA device with the given serial. If the passcode it was
+	// given was obviously wrong (e.g. mistyped), it can return an error wrapping ErrMFACodeRejected to be asked again.
+	MFACode(ctx context.Context, serial string) (string, error)
+}
+
+// MFAProviderFunc adapts a function to the MFAProvider interface.
+type MFAProviderFunc func(ctx context.Context, serial string) (string, error)
+
+// MFACode calls f.
+func (f MFAProviderFunc) MFACode(ctx context.Context, serial string) (string, error) {
+	return f(ctx, serial)
+}
+
+// StaticMFACode returns an MFAProvider that always returns code - e.g. one that was passed on the command line.
+func StaticMFACode(code string) MFAProvider {
+	return MFAProviderFunc(func(ctx context.Context, serial string) (string, error) {
+		return code, nil
+	})
+}
+
+// MFADeviceSelector picks which of an auth source's MFA devices to use.
+type MFADeviceSelector interface {
+	// SelectMFADevice returns one of serials - The serial ARNs of the MFA devices for source.
+	SelectMFADevice(ctx context.Context, source string, serials []string) (string, error)
+}
+
+// MFADeviceSelectorFunc adapts a function to the MFADeviceSelector interface.
+type MFADeviceSelectorFunc func(ctx context.Context, source string, serials []string) (string, error)
+
+// SelectMFADevice calls f.
+func (f MFADeviceSelectorFunc) SelectMFADevice(ctx context.Context, source string, serials []string) (string, error) {
+	return f(ctx, source, serials)
+}
@@ -0,0 +1,254 @@
+package roo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// totpDigits is the number of digits in the codes generated by virtual MFA devices.
+const totpDigits = 6
+
+// unsafeKeyCharacters are replaced when MFA serials are turned into key store keys.
+var unsafeKeyCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// VirtualMFADevice is a virtual MFA device created by EnrollMFADevice.
+type VirtualMFADevice struct {
+	// Serial is the serial ARN of the device.
+	Serial string
+	// Seed is the base32-encoded secret that the device's codes are generated from.
+	Seed string
+	// UserName is the name of the IAM user that the device is for, and UserARN is their ARN.
+	UserName string
+	UserARN  string
+}
+
+// URI returns the otpauth:// URI for the device, which authenticator apps can add it from (usually as a QR code).
+func (d *VirtualMFADevice) URI() string {
+	account := mfaSerialAccountLabel(d.Serial, d.UserName)
+	query := url.Values{"secret": {d.Seed}, "issuer": {"Amazon Web Services"}}
+	return "otpauth://totp/" + url.PathEscape("Amazon Web Services:"+account) + "?" + query.Encode()
+}
+
+// mfaSerialAccountLabel returns the label that authenticator apps show for a device - The user's name and the ID of
+// their account, as the AWS console uses.
+func mfaSerialAccountLabel(serial, userName string) string {
+	if accountID := config.MFASerialAccountID(serial); accountID != "" {
+		return userName + "@" + accountID
+	}
+	return userName
+}
+
+// MFACodeCollector returns two consecutive codes from a new virtual MFA device, to enable it with - e.g. by showing
+// the user the device's URI as a QR code, and asking them for the codes that their authenticator app shows.
+type MFACodeCollector func(ctx context.Context, device *VirtualMFADevice) (code1, code2 string, err error)
+
+// EnrollMFADevice creates a virtual MFA device for the IAM user that the named auth source (or the default auth source,
+// if sourceName is empty) logs in as, and enables it with the codes from collectCodes. deviceName defaults to the
+// user's name. If storeSeed is true, the device's seed is kept in the auth source's key backend, so that roo can
+// generate codes itself (See config.MFACodeSourceTOTP).
+//
+// If the device can't be enabled, it's deleted again.
+func (c *Client) EnrollMFADevice(
+	ctx context.Context,
+	sourceName, deviceName string,
+	storeSeed bool,
+	collectCodes MFACodeCollector,
+) (*VirtualMFADevice, error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	if storeSeed {
+		if _, err := c.keyStore(source); err != nil {
+			return nil, fmt.Errorf("unable to store the seed: %w", err)
+		}
+	}
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := c.stsClient(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get caller identity: %w", err)
+	}
+	device := &VirtualMFADevice{UserARN: aws.ToString(identity.Arn)}
+	if device.UserName = iamUserName(device.UserARN); device.UserName == "" {
+		return nil, fmt.Errorf("MFA devices can only be enrolled for IAM users, not %s", device.UserARN)
+	}
+	if deviceName == "" {
+		deviceName = device.UserName
+	}
+
+	iamClient := c.iamClient(cfg)
+	created, err := iamClient.CreateVirtualMFADevice(ctx, &iam.CreateVirtualMFADeviceInput{
+		VirtualMFADeviceName: aws.String(deviceName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a virtual MFA device: %w", err)
+	}
+	device.Serial = aws.ToString(created.VirtualMFADevice.SerialNumber)
+	device.Seed = string(created.VirtualMFADevice.Base32StringSeed)
+	slog.Info("Created a virtual MFA device", "serial", device.Serial)
+
+	// rollback deletes the device (and its seed), so that enrolling can be tried again from scratch.
+	rollback := func(cause error) error {
+		ctx := context.WithoutCancel(ctx)
+		if storeSeed {
+			if err := c.deleteMFASeed(source, device.Serial); err != nil {
+				slog.Warn("Unable to delete the seed of the MFA device", "serial", device.Serial, "error", err)
+			}
+		}
+		_, err := iamClient.DeleteVirtualMFADevice(ctx, &iam.DeleteVirtualMFADeviceInput{
+			SerialNumber: aws.String(device.Serial),
+		})
+		if err != nil {
+			return fmt.Errorf("%w (the MFA device, %s, couldn't be deleted either: %s)", cause, device.Serial, err)
+		}
+		return cause
+	}
+
+	// The seed is stored first, as the device can't be used without it once it's been enabled.
+	if storeSeed {
+		if err := c.putMFASeed(source, device.Serial, device.Seed); err != nil {
+			return nil, rollback(fmt.Errorf("unable to store the seed: %w", err))
+		}
+	}
+	code1, code2, err := collectCodes(ctx, device)
+	if err != nil {
+		return nil, rollback(err)
+	}
+	_, err = iamClient.EnableMFADevice(ctx, &iam.EnableMFADeviceInput{
+		UserName:            aws.String(device.UserName),
+		SerialNumber:        aws.String(device.Serial),
+		AuthenticationCode1: aws.String(code1),
+		AuthenticationCode2: aws.String(code2),
+	})
+	if err != nil {
+		return nil, rollback(fmt.Errorf("unable to enable the MFA device: %w", err))
+	}
+	// AWS won't accept the second code again, so make sure it isn't used to assume a role straight away.
+	if c.UsedCodes != nil {
+		if err := c.UsedCodes.Record(device.Serial, code2, time.Now()); err != nil {
+			slog.Warn("Unable to record the MFA code as used", "error", err)
+		}
+	}
+	return device, nil
+}
+
+// MFADevices returns the serials of the MFA devices enabled for the IAM user that the named auth source (or the
+// default auth source, if sourceName is empty) logs in as.
+func (c *Client) MFADevices(ctx context.Context, sourceName string) ([]string, error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.iamClient(cfg).ListMFADevices(ctx, &iam.ListMFADevicesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list MFA devices: %w", err)
+	}
+	serials := make([]string, 0, len(output.MFADevices))
+	for _, device := range output.MFADevices {
+		serials = append(serials, aws.ToString(device.SerialNumber))
+	}
+	return serials, nil
+}
+
+// iamUserName returns the name of the IAM user from their ARN (e.g. arn:aws:iam::000000000000:user/path/name), or an
+// empty string if it isn't an IAM user's ARN.
+func iamUserName(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "iam" || !strings.HasPrefix(parts[5], "user/") {
+		return ""
+	}
+	return parts[5][strings.LastIndex(parts[5], "/")+1:]
+}
+
+// MFASeedKey returns the key that the seed of the MFA device with the given serial is stored under in key stores.
+func MFASeedKey(serial string) string {
+	return "mfa-seed-" + unsafeKeyCharacters.ReplaceAllString(strings.TrimPrefix(serial, "arn:aws:iam::"), "-")
+}
+
+// putMFASeed stores the seed of an MFA device in source's key backend.
+func (c *Client) putMFASeed(source *config.AuthSource, serial, seed string) error {
+	store, err := c.keyStore(source)
+	if err != nil {
+		return err
+	}
+	return store.Put(MFASeedKey(serial), &cachedcredsprovider.CachedCredentials{
+		IssuedAt: time.Now(),
+		// The seed is kept as a secret, as that's what the key stores protect.
+		Values: cachedcredsprovider.Credentials{SecretAccessKey: seed},
+	})
+}
+
+// deleteMFASeed removes the seed of an MFA device from source's key backend.
+func (c *Client) deleteMFASeed(source *config.AuthSource, serial string) error {
+	store, err := c.keyStore(source)
+	if err != nil {
+		return err
+	}
+	return store.Delete(MFASeedKey(serial))
+}
+
+// mfaCode returns the code to use with the MFA device with the given serial - Generated from its stored seed if the
+// auth source uses the totp code source, and from the MFA provider otherwise.
+func (c *Client) mfaCode(ctx context.Context, source *config.AuthSource, serial string) (string, error) {
+	if source.MFACodeSource != config.MFACodeSourceTOTP {
+		return c.MFA.MFACode(ctx, serial)
+	}
+	store, err := c.keyStore(source)
+	if err != nil {
+		return "", err
+	}
+	stored, err := store.Get(MFASeedKey(serial))
+	if err != nil {
+		return "", fmt.Errorf("unable to read the seed of MFA device %s: %w", serial, err)
+	}
+	if stored == nil {
+		return "", fmt.Errorf(
+			"the seed of MFA device %s isn't stored - Enroll it with 'roo mfa enroll -store-seed'", serial,
+		)
+	}
+	return TOTPCode(stored.Values.SecretAccessKey, time.Now())
+}
+
+// TOTPCode returns the code that a virtual MFA device with the given (base32-encoded) seed shows at t (See RFC 6238).
+func TOTPCode(seed string, t time.Time) (string, error) {
+	seed = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(seed, " ", ""), "="))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(seed)
+	if err != nil {
+		return "", fmt.Errorf("invalid MFA seed: %w", err)
+	}
+	if len(key) == 0 {
+		return "", errors.New("invalid MFA seed: it's empty")
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod.Seconds())))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
@@ -0,0 +1,168 @@
+package roo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// testSeed is the seed used by the test vectors in RFC 6238, base32-encoded.
+const testSeed = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		code, err := TOTPCode(testSeed, time.Unix(test.unix, 0))
+		if err != nil || code != test.code {
+			t.Errorf("Expected code %s at %d, got %s (error: %v)", test.code, test.unix, code, err)
+		}
+	}
+	if code, err := TOTPCode("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); code != "287082" {
+		t.Errorf("Expected lower case seeds with spaces to be accepted, got %s (error: %v)", code, err)
+	}
+	if _, err := TOTPCode("not base32!", time.Now()); err == nil {
+		t.Error("Expected an error for an invalid seed")
+	}
+}
+
+func TestEnrollMFADevice(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	const serial = "arn:aws:iam::999999999999:mfa/someone"
+	fake.actions["CreateVirtualMFADevice"] = func(r *http.Request) (string, string, string) {
+		if r.Form.Get("VirtualMFADeviceName") != "someone" {
+			t.Errorf("Expected the device to be named after the user, got %s", r.Form.Get("VirtualMFADeviceName"))
+		}
+		return "<VirtualMFADevice><SerialNumber>" + serial + "</SerialNumber><Base32StringSeed>" +
+			base64.StdEncoding.EncodeToString([]byte(testSeed)) + "</Base32StringSeed></VirtualMFADevice>", "", ""
+	}
+	var enabled []string
+	fake.actions["EnableMFADevice"] = func(r *http.Request) (string, string, string) {
+		enabled = append(enabled, r.Form.Get("UserName"), r.Form.Get("SerialNumber"),
+			r.Form.Get("AuthenticationCode1"), r.Form.Get("AuthenticationCode2"))
+		return "", "", ""
+	}
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		// The code may have been generated just before a new period started.
+		current, _ := TOTPCode(testSeed, time.Now())
+		previous, _ := TOTPCode(testSeed, time.Now().Add(-totpPeriod))
+		code := r.Form.Get("TokenCode")
+		if r.Form.Get("SerialNumber") != serial || (code != current && code != previous) {
+			t.Errorf("Expected a code generated from the seed, got %s", r.Form.Get("TokenCode"))
+		}
+		return sessionTokenResult("ASIASESSION"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+
+	conf := authSourceConfig()
+	conf.AuthSources[0].KeyBackend = "test"
+	client := New(conf, memoryCache{}, nil)
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+	store := cachedcredsprovider.NewMemoryStore()
+	client.OpenKeyStore = func(backend string) (cachedcredsprovider.Store, error) {
+		return store, nil
+	}
+	fake.actions["ListAccessKeys"] = func(r *http.Request) (string, string, string) {
+		return listAccessKeysResult("AKIASTORED", "2020-01-02T03:04:05Z"), "", ""
+	}
+	if err := client.StoreAccessKey(context.Background(), "corporate", "AKIASTORED", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := client.EnrollMFADevice(context.Background(), "corporate", "", true,
+		func(ctx context.Context, device *VirtualMFADevice) (string, string, error) {
+			return "123456", "654321", nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Unable to enroll the MFA device: %s", err)
+	}
+	if device.Serial != serial || device.Seed != testSeed || device.UserName != "someone" {
+		t.Errorf("Unexpected device: %+v", device)
+	}
+	expectedURI := "otpauth://totp/Amazon%20Web%20Services:someone@999999999999" +
+		"?issuer=Amazon+Web+Services&secret=" + testSeed
+	if device.URI() != expectedURI {
+		t.Errorf("Expected URI %s, got %s", expectedURI, device.URI())
+	}
+	if len(enabled) != 4 || enabled[0] != "someone" || enabled[1] != serial || enabled[2] != "123456" ||
+		enabled[3] != "654321" {
+		t.Errorf("Unexpected EnableMFADevice request: %v", enabled)
+	}
+
+	// The stored seed lets roo generate codes itself.
+	conf.AuthSources[0].MFASerials = []string{serial}
+	conf.AuthSources[0].MFACodeSource = config.MFACodeSourceTOTP
+	if _, err := client.Credentials(context.Background(), "prod-readonly"); err != nil {
+		t.Fatalf("Unable to assume a role with a generated MFA code: %s", err)
+	}
+}
+
+func TestEnrollMFADeviceRollsBack(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["CreateVirtualMFADevice"] = func(r *http.Request) (string, string, string) {
+		return "<VirtualMFADevice><SerialNumber>arn:aws:iam::999999999999:mfa/phone</SerialNumber>" +
+			"<Base32StringSeed>" + base64.StdEncoding.EncodeToString([]byte(testSeed)) + "</Base32StringSeed>" +
+			"</VirtualMFADevice>", "", ""
+	}
+	var deleted []string
+	fake.actions["DeleteVirtualMFADevice"] = func(r *http.Request) (string, string, string) {
+		deleted = append(deleted, r.Form.Get("SerialNumber"))
+		return "", "", ""
+	}
+	client := New(authSourceConfig(), memoryCache{}, nil)
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+
+	cancelled := errors.New("cancelled")
+	_, err := client.EnrollMFADevice(context.Background(), "", "phone", false,
+		func(ctx context.Context, device *VirtualMFADevice) (string, string, error) {
+			return "", "", cancelled
+		},
+	)
+	if !errors.Is(err, cancelled) {
+		t.Errorf("Expected the error from collecting the codes, got %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "arn:aws:iam::999999999999:mfa/phone" {
+		t.Errorf("Expected the device to be deleted, got %v", deleted)
+	}
+	if fake.calls["EnableMFADevice"] != 0 {
+		t.Error("Expected the device not to be enabled")
+	}
+}
+
+func TestMFADevices(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["ListMFADevices"] = func(r *http.Request) (string, string, string) {
+		return "<MFADevices><member><UserName>someone</UserName>" +
+			"<SerialNumber>arn:aws:iam::999999999999:mfa/phone</SerialNumber>" +
+			"<EnableDate>2026-01-02T03:04:05Z</EnableDate></member></MFADevices><IsTruncated>false</IsTruncated>", "", ""
+	}
+	client := New(authSourceConfig(), memoryCache{}, nil)
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+
+	serials, err := client.MFADevices(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Unable to list MFA devices: %s", err)
+	}
+	if len(serials) != 1 || serials[0] != "arn:aws:iam::999999999999:mfa/phone" {
+		t.Errorf("Unexpected MFA devices: %v", serials)
+	}
+}
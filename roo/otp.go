@@ -0,0 +1,156 @@
+package roo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// totpPeriod is how often MFA devices generate a new code.
+const totpPeriod = 30 * time.Second
+
+// ErrMFACodeRejected is returned (wrapped) when AWS rejects an MFA code - e.g. because it was mistyped, has expired,
+// or has already been used.
+var ErrMFACodeRejected = errors.New("the MFA code was rejected")
+
+// mfaRejectedError returns an error wrapping ErrMFACodeRejected if err is STS rejecting an MFA code, or nil otherwise.
+func mfaRejectedError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied" &&
+		strings.Contains(apiErr.ErrorMessage(), "MultiFactorAuthentication") {
+		return fmt.Errorf("%w: %s", ErrMFACodeRejected, apiErr.ErrorMessage())
+	}
+	return nil
+}
+
+// UsedCodes remembers the last MFA code used with each device, so that roo can avoid submitting it again - AWS
+// rejects codes that have already been used. Codes are stored as hashes.
+type UsedCodes struct {
+	// Path is the JSON file that used codes are recorded in.
+	Path string
+}
+
+// usedCode is an entry in the UsedCodes file.
+type usedCode struct {
+	CodeSHA256 string    `json:"code_sha256"`
+	UsedAt     time.Time `json:"used_at"`
+}
+
+// NewUsedCodes returns a UsedCodes that records codes in the file at path.
+func NewUsedCodes(path string) *UsedCodes {
+	return &UsedCodes{Path: path}
+}
+
+// load returns the recorded codes, keyed by MFA serial. A missing file isn't an error.
+func (u *UsedCodes) load() (map[string]usedCode, error) {
+	codes := map[string]usedCode{}
+	data, err := os.ReadFile(u.Path)
+	if os.IsNotExist(err) {
+		return codes, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", u.Path, err)
+	}
+	return codes, nil
+}
+
+// lastUsed returns when a code was last used with the device with the given serial, and whether it was code.
+func (u *UsedCodes) lastUsed(serial, code string) (time.Time, bool, error) {
+	codes, err := u.load()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	entry := codes[serial]
+	return entry.UsedAt, entry.CodeSHA256 == hashCode(code), nil
+}
+
+// lastUsedAt returns when a code was last used with the device with the given serial, or the zero time if one hasn't
+// been.
+func (u *UsedCodes) lastUsedAt(serial string) (time.Time, error) {
+	codes, err := u.load()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return codes[serial].UsedAt, nil
+}
+
+// Record records that code was used with the device with the given serial at usedAt.
+func (u *UsedCodes) Record(serial, code string, usedAt time.Time) error {
+	codes, err := u.load()
+	if err != nil {
+		return err
+	}
+	codes[serial] = usedCode{CodeSHA256: hashCode(code), UsedAt: usedAt}
+	data, err := json.MarshalIndent(codes, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that the recorded codes aren't lost if the write fails part way through (or
+	// another roo reads the file while it's being written).
+	tempFile, err := os.CreateTemp(filepath.Dir(u.Path), filepath.Base(u.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), u.Path)
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// nextCodeAt returns when the device will generate the code after the one that was current at t.
+func nextCodeAt(t time.Time) time.Time {
+	return t.Truncate(totpPeriod).Add(totpPeriod)
+}
+
+// waitUntil blocks until t, or until ctx is done.
+func waitUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitForFreshCode waits for the device with the given serial to generate a new code, if a code was used with it
+// during the current period - Otherwise the user would most likely be looking at the code that was just used.
+func (c *Client) waitForFreshCode(ctx context.Context, serial string) error {
+	if c.UsedCodes == nil {
+		return nil
+	}
+	usedAt, err := c.UsedCodes.lastUsedAt(serial)
+	if err != nil {
+		slog.Debug("Unable to check when an MFA code was last used", "error", err)
+		return nil
+	}
+	if next := nextCodeAt(usedAt); time.Now().Before(next) {
+		slog.Warn("An MFA code was used with this device moments ago - Waiting for the next one",
+			"wait", time.Until(next).Round(time.Second))
+		return waitUntil(ctx, next)
+	}
+	return nil
+}
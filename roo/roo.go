@@ -0,0 +1,290 @@
+// Package roo assumes IAM roles from an authentication account with MFA, caching the resulting credentials so that
+// the MFA code is only needed when they expire. It's the engine behind the roo CLI, and can be embedded in other tools.
+//
+//	conf, err := config.Load(configFilePath)
+//	client := roo.New(conf, roo.NewFileCache(cacheDir), roo.MFAProviderFunc(promptForCode))
+//	creds, err := client.Credentials(ctx, "prod-readonly")
+package roo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// ErrNoRole is returned when a role isn't specified, and the config doesn't have a default role.
+var ErrNoRole = errors.New("no role was specified, and no default role is configured")
+
+// Client assumes roles defined in a config.Config.
+type Client struct {
+	Config *config.Config
+	// Profile is the AWS config profile used to create the session for the auth source. If empty, the auth source's
+	// stored access key or profile (or Config.DefaultProfile) is used, falling back to the AWS SDK's default credential
+	// chain.
+	Profile string
+	// AuthSource is the name of the auth source to assume every role from, regardless of the roles' auth_source and
+	// which auth source is the default - e.g. a web_identity source in CI. If empty, the config decides.
+	AuthSource string
+	// MFASerial is the serial ARN of the MFA device to use when assuming roles. If empty, the auth source's MFA devices
+	// (or Config.MFASerial) are used.
+	MFASerial string
+	// MFA provides the one-time passcode when credentials need to be refreshed. If nil, refreshing credentials
+	// returns ErrMFARequired.
+	MFA MFAProvider
+	// MFADeviceSelector picks the MFA device to use when an auth source has more than one. If nil, the first one is
+	// used.
+	MFADeviceSelector MFADeviceSelector
+	// OpenKeyStore opens the stores that long-term access keys are kept in, for auth sources with a key_backend. If
+	// nil, those auth sources can't be used.
+	OpenKeyStore KeyStoreOpener
+	// Cache stores credentials between calls (and processes). If nil, credentials aren't cached.
+	Cache Cache
+	// UsedCodes prevents MFA codes from being reused. If nil, used codes aren't tracked.
+	UsedCodes *UsedCodes
+	// STSEndpoint overrides the STS endpoint - e.g. to use a VPC endpoint.
+	STSEndpoint string
+	// IAMEndpoint overrides the IAM endpoint.
+	IAMEndpoint string
+	// TimeSource is a URL whose Date header is used to measure clock skew. If empty, the Date header of STS responses
+	// is used.
+	TimeSource string
+	// ClockSkewThreshold is how far the local clock can drift from AWS before a warning is logged. Defaults to
+	// DefaultClockSkewThreshold.
+	ClockSkewThreshold time.Duration
+	// HTTPClient is used for calls to the AWS federation endpoint. Defaults to a client with Timeout.
+	HTTPClient *http.Client
+	// Timeout limits how long each request to AWS can take. Zero means no limit - Use the context to limit the
+	// overall time taken.
+	Timeout time.Duration
+	// MaxAttempts is the maximum number of attempts made for each STS request. Zero uses the AWS SDK default (3).
+	MaxAttempts int
+	// MaxBackoff is the maximum delay between attempts. Zero uses the AWS SDK default (20 seconds).
+	MaxBackoff time.Duration
+}
+
+// Session is a set of credentials for an assumed role.
+type Session struct {
+	Role        *config.RoleConfig
+	Credentials aws.Credentials
+	ExpiresAt   time.Time
+	// SessionARN is the ARN of the assumed role session.
+	SessionARN string
+	// SourceIdentity is the ARN of the identity that assumed the role.
+	SourceIdentity string
+	// Refreshed is true if the credentials were issued by STS for this call, rather than loaded from the cache.
+	Refreshed bool
+}
+
+// New returns a Client for the roles in conf. cache and mfa may be nil - See Client for what that means.
+func New(conf *config.Config, cache Cache, mfa MFAProvider) *Client {
+	return &Client{Config: conf, Cache: cache, MFA: mfa}
+}
+
+// ResolveRole returns the role matching roleRef (See config.Config.GetRole for how it's matched), or the default role
+// if roleRef is empty.
+func (c *Client) ResolveRole(roleRef string) (*config.RoleConfig, error) {
+	if roleRef == "" {
+		role := c.Config.GetDefaultRole()
+		if role.ARN == "" {
+			return nil, ErrNoRole
+		}
+		return role, nil
+	}
+	return c.Config.GetRole(roleRef)
+}
+
+// Credentials returns credentials for the role matching roleRef, from the cache if they're still valid.
+func (c *Client) Credentials(ctx context.Context, roleRef string) (aws.Credentials, error) {
+	role, err := c.ResolveRole(roleRef)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	session, err := c.AssumeRole(ctx, role, false)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return session.Credentials, nil
+}
+
+// AssumeRole returns a session for role. Cached credentials are used if they're still valid, unless forceRefresh is
+// true - Otherwise the role is assumed with MFA, and the new credentials are cached.
+func (c *Client) AssumeRole(ctx context.Context, role *config.RoleConfig, forceRefresh bool) (*Session, error) {
+	provider, err := c.provider(role, forceRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if forceRefresh {
+		provider.Expire()
+	}
+	cached, refreshed, err := provider.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if source, err := c.roleAuthSource(role); err == nil {
+		warnAboutAccessKeyAge(source, cached.SourceAccessKeyID, cached.SourceAccessKeyCreatedAt)
+	}
+	return newSession(role, cached, refreshed), nil
+}
+
+// Provider returns an AWS SDK credentials provider for role, which assumes the role with MFA whenever the cached
+// credentials have expired. Use its V1 method for aws-sdk-go (v1).
+func (c *Client) Provider(role *config.RoleConfig) (*cachedcredsprovider.CachedCredProvider, error) {
+	return c.provider(role, false)
+}
+
+// provider returns the credentials provider for role. If refreshMFASession is true, the role's auth source gets a new
+// MFA session (if it uses one) the next time the role is assumed.
+func (c *Client) provider(
+	role *config.RoleConfig,
+	refreshMFASession bool,
+) (*cachedcredsprovider.CachedCredProvider, error) {
+	cacheKey, err := CacheKey(role)
+	if err != nil {
+		return nil, err
+	}
+	refresh := func(ctx context.Context) (*cachedcredsprovider.CachedCredentials, error) {
+		return c.assumeRoleWithMFA(ctx, role, refreshMFASession)
+	}
+	return cachedcredsprovider.New(c.Cache, cacheKey, refresh), nil
+}
+
+// CallerIdentity returns the identity that the credentials from provider belong to - e.g. a provider returned by
+// Provider.
+func (c *Client) CallerIdentity(
+	ctx context.Context,
+	provider aws.CredentialsProvider,
+) (*sts.GetCallerIdentityOutput, error) {
+	cfg, err := c.awsConfig(ctx, awsconfig.WithCredentialsProvider(provider))
+	if err != nil {
+		return nil, err
+	}
+	return c.stsClient(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+}
+
+// AccountAlias returns the alias of the account that the credentials from provider belong to, or an empty string if
+// the account doesn't have one. This needs the iam:ListAccountAliases permission.
+func (c *Client) AccountAlias(ctx context.Context, provider aws.CredentialsProvider) (string, error) {
+	cfg, err := c.awsConfig(ctx, awsconfig.WithCredentialsProvider(provider))
+	if err != nil {
+		return "", err
+	}
+	output, err := c.iamClient(cfg).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		return "", err
+	}
+	if len(output.AccountAliases) == 0 {
+		return "", nil
+	}
+	return output.AccountAliases[0], nil
+}
+
+// BaseCredentials returns the credentials for an auth source (i.e. the ones that roles are assumed with) - Its stored
+// access key, or as resolved by the AWS SDK from Profile (or the auth source's profile). For web_identity auth sources,
+// they're new credentials for the auth source's role, from its web identity token. An empty sourceName means the
+// default auth source.
+func (c *Client) BaseCredentials(ctx context.Context, sourceName string) (aws.Credentials, error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if source.IsWebIdentity() {
+		session, err := c.getWebIdentitySession(ctx, source)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return session.AWSCredentials(), nil
+	}
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if cfg.Credentials == nil {
+		return aws.Credentials{}, errors.New("no credentials are configured for the authentication account")
+	}
+	return cfg.Credentials.Retrieve(ctx)
+}
+
+// stsClient returns an STS client for cfg, using STSEndpoint if it's set.
+func (c *Client) stsClient(cfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if c.STSEndpoint != "" {
+			o.BaseEndpoint = aws.String(c.STSEndpoint)
+		}
+	})
+}
+
+// iamClient returns an IAM client for cfg, using IAMEndpoint if it's set.
+func (c *Client) iamClient(cfg aws.Config) *iam.Client {
+	return iam.NewFromConfig(cfg, func(o *iam.Options) {
+		if c.IAMEndpoint != "" {
+			o.BaseEndpoint = aws.String(c.IAMEndpoint)
+		}
+	})
+}
+
+// awsConfig loads the AWS SDK config, with the client's timeout and retry settings applied.
+func (c *Client) awsConfig(
+	ctx context.Context,
+	optFns ...func(*awsconfig.LoadOptions) error,
+) (aws.Config, error) {
+	optFns = append([]func(*awsconfig.LoadOptions) error{
+		awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(c.Timeout)),
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				if c.MaxAttempts > 0 {
+					o.MaxAttempts = c.MaxAttempts
+				}
+				if c.MaxBackoff > 0 {
+					o.MaxBackoff = c.MaxBackoff
+				}
+			})
+		}),
+	}, optFns...)
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	// STS is available in every region, so there's no need to make people configure one.
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return cfg, nil
+}
+
+// profile returns the AWS config profile for source, or an empty string for the SDK default.
+func (c *Client) profile(source *config.AuthSource) string {
+	if c.Profile != "" {
+		return c.Profile
+	}
+	return source.Profile
+}
+
+// httpClient returns the HTTP client for calls to the AWS federation endpoint.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: c.Timeout}
+}
+
+func newSession(role *config.RoleConfig, cached *cachedcredsprovider.CachedCredentials, refreshed bool) *Session {
+	return &Session{
+		Role:           role,
+		Credentials:    cached.AWSCredentials(),
+		ExpiresAt:      cached.ExpiresAt,
+		SessionARN:     cached.SessionARN,
+		SourceIdentity: cached.SourceIdentity,
+		Refreshed:      refreshed,
+	}
+}
@@ -0,0 +1,218 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// memoryCache is a Cache that keeps credentials in a map.
+type memoryCache map[string]*cachedcredsprovider.CachedCredentials
+
+func (c memoryCache) Get(key string) (*cachedcredsprovider.CachedCredentials, error) {
+	return c[key], nil
+}
+
+func (c memoryCache) Put(key string, creds *cachedcredsprovider.CachedCredentials) error {
+	c[key] = creds
+	return nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		MFASerial: "arn:aws:iam::000000000000:mfa/someone",
+		Roles: []config.RoleConfig{
+			{Name: "prod-readonly", ARN: "arn:aws:iam::000000000000:role/ReadOnly", IsDefault: true},
+		},
+	}
+}
+
+func TestCredentialsFromCache(t *testing.T) {
+	cache := memoryCache{
+		"000000000000-ReadOnly": {
+			ExpiresAt: time.Now().Add(time.Hour),
+			Values:    cachedcredsprovider.Credentials{AccessKeyID: "ASIACACHED"},
+		},
+	}
+	client := New(testConfig(), cache, nil)
+
+	creds, err := client.Credentials(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Unable to get cached credentials: %s", err)
+	}
+	if creds.AccessKeyID != "ASIACACHED" {
+		t.Errorf("Unexpected access key ID: %s", creds.AccessKeyID)
+	}
+}
+
+func TestCredentialsRequireMFAWhenExpired(t *testing.T) {
+	cache := memoryCache{
+		"000000000000-ReadOnly": {
+			ExpiresAt: time.Now().Add(-time.Hour),
+			Values:    cachedcredsprovider.Credentials{AccessKeyID: "ASIAEXPIRED"},
+		},
+	}
+	client := New(testConfig(), cache, nil)
+
+	if _, err := client.Credentials(context.Background(), "prod-readonly"); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("Expected ErrMFARequired, got %v", err)
+	}
+}
+
+func TestResolveRoleWithoutDefault(t *testing.T) {
+	conf := testConfig()
+	conf.Roles[0].IsDefault = false
+	if _, err := New(conf, nil, nil).ResolveRole(""); !errors.Is(err, ErrNoRole) {
+		t.Errorf("Expected ErrNoRole, got %v", err)
+	}
+}
+
+func TestBaseCredentials(t *testing.T) {
+	newFakeSTS(t)
+	client := New(testConfig(), nil, nil)
+	creds, err := client.BaseCredentials(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Unable to resolve the base credentials: %s", err)
+	}
+	if creds.AccessKeyID != "AKIAFAKE" {
+		t.Errorf("Unexpected access key ID: %s", creds.AccessKeyID)
+	}
+
+	client.Profile = "missing"
+	if _, err := client.BaseCredentials(context.Background(), ""); err == nil {
+		t.Error("Expected an error for a profile that doesn't exist")
+	}
+}
+
+func TestAccountAlias(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["ListAccountAliases"] = func(r *http.Request) (string, string, string) {
+		return "<AccountAliases><member>acme-prod</member></AccountAliases><IsTruncated>false</IsTruncated>", "", ""
+	}
+	client := New(testConfig(), nil, nil)
+	client.IAMEndpoint = server.URL
+
+	provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "ASIAFAKE", SecretAccessKey: "fake"}, nil
+	})
+	alias, err := client.AccountAlias(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("Unable to get the account alias: %s", err)
+	}
+	if alias != "acme-prod" {
+		t.Errorf("Unexpected account alias: %s", alias)
+	}
+}
+
+// codes returns an MFA provider that returns each of codes in turn.
+func codes(codes ...string) MFAProvider {
+	return MFAProviderFunc(func(ctx context.Context, serial string) (string, error) {
+		code := codes[0]
+		codes = codes[1:]
+		return code, nil
+	})
+}
+
+func TestAssumeRoleRepromptsOnRejectedMFACode(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		if r.Form.Get("TokenCode") != "222222" {
+			return "", "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."
+		}
+		return assumeRoleResult("ASIANEW"), "", ""
+	}
+	client := New(testConfig(), memoryCache{}, codes("111111", "222222"))
+	client.STSEndpoint = server.URL
+	client.UsedCodes = NewUsedCodes(filepath.Join(t.TempDir(), "used_codes.json"))
+
+	creds, err := client.Credentials(context.Background(), "prod-readonly")
+	if err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if creds.AccessKeyID != "ASIANEW" || fake.calls["AssumeRole"] != 2 {
+		t.Errorf("Expected credentials on the second attempt, got %+v after %d", creds, fake.calls["AssumeRole"])
+	}
+	if _, reused, _ := client.UsedCodes.lastUsed("arn:aws:iam::000000000000:mfa/someone", "222222"); !reused {
+		t.Error("Expected the accepted MFA code to be recorded as used")
+	}
+}
+
+func TestAssumeRoleRepromptsOnInvalidMFACode(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return assumeRoleResult("ASIANEW"), "", ""
+	}
+	prompts := 0
+	client := New(testConfig(), memoryCache{}, MFAProviderFunc(func(ctx context.Context, serial string) (string, error) {
+		prompts++
+		if prompts < 3 {
+			return "", fmt.Errorf("%w: one-time passcode must only contain digits", ErrMFACodeRejected)
+		}
+		return "333333", nil
+	}))
+	client.STSEndpoint = server.URL
+
+	if _, err := client.Credentials(context.Background(), "prod-readonly"); err != nil {
+		t.Fatalf("Unable to get credentials: %s", err)
+	}
+	if prompts != 3 || fake.calls["AssumeRole"] != 1 {
+		t.Errorf("Expected a valid code on the third prompt, got %d prompts and %d calls", prompts,
+			fake.calls["AssumeRole"])
+	}
+
+	// The MFA provider is asked no more than mfa_attempts times in all.
+	prompts = 0
+	conf := testConfig()
+	conf.MFAAttempts = 2
+	client = New(conf, memoryCache{}, MFAProviderFunc(func(ctx context.Context, serial string) (string, error) {
+		prompts++
+		return "", fmt.Errorf("%w: one-time passcode must only contain digits", ErrMFACodeRejected)
+	}))
+	client.STSEndpoint = server.URL
+	if _, err := client.Credentials(context.Background(), "prod-readonly"); !errors.Is(err, ErrMFACodeRejected) {
+		t.Errorf("Expected ErrMFACodeRejected, got %v", err)
+	}
+	if prompts != 2 {
+		t.Errorf("Expected 2 prompts, got %d", prompts)
+	}
+}
+
+func TestAssumeRoleGivesUpOnRepeatedMFACode(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return "", "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."
+	}
+	client := New(testConfig(), memoryCache{}, StaticMFACode("111111"))
+	client.STSEndpoint = server.URL
+
+	_, err := client.Credentials(context.Background(), "prod-readonly")
+	if !errors.Is(err, ErrMFACodeRejected) {
+		t.Errorf("Expected ErrMFACodeRejected, got %v", err)
+	}
+	if fake.calls["AssumeRole"] != 1 {
+		t.Errorf("Expected the static code to only be submitted once, got %d attempts", fake.calls["AssumeRole"])
+	}
+}
+
+func TestAssumeRoleDoesNotRetryOtherErrors(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return "", "AccessDenied", "User is not authorized to perform: sts:AssumeRole"
+	}
+	client := New(testConfig(), memoryCache{}, codes("111111", "222222"))
+	client.STSEndpoint = server.URL
+
+	_, err := client.Credentials(context.Background(), "prod-readonly")
+	if err == nil || errors.Is(err, ErrMFACodeRejected) {
+		t.Errorf("Expected a non-MFA error, got %v", err)
+	}
+}
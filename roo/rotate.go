@@ -0,0 +1,296 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/smithy-go"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// New access keys can take a few seconds to be accepted everywhere, so they're checked up to accessKeyVerifyAttempts
+// times, accessKeyVerifyDelay apart, before being rolled back.
+var (
+	accessKeyVerifyAttempts = 10
+	accessKeyVerifyDelay    = 2 * time.Second
+)
+
+// RotatedAccessKey is the result of RotateAccessKey.
+type RotatedAccessKey struct {
+	// UserARN is the ARN of the IAM user that the access keys belong to.
+	UserARN        string
+	OldAccessKeyID string
+	NewAccessKeyID string
+	// Profile is the AWS config profile that the new access key was written to, or an empty string if it was stored
+	// in the auth source's key backend.
+	Profile string
+}
+
+// ProfileKeyWriter writes an access key to the credentials of an AWS config profile.
+type ProfileKeyWriter func(profile, accessKeyID, secretAccessKey string) error
+
+// RotateAccessKey replaces the long-term access key of the named auth source (or the default auth source, if
+// sourceName is empty):
+//
+//  1. A new access key is created with the auth source's MFA session, as IAM requires MFA.
+//  2. AWS is asked (with GetCallerIdentity) who the new access key belongs to, to make sure that it works.
+//  3. The new access key is stored in the auth source's key backend, or written to its profile with writeProfile.
+//  4. The cached session for the auth source is expired, as it was started with the old access key.
+//  5. The old access key is deleted.
+//
+// If any of the first three steps fail, the new access key is deleted, leaving the old one as it was. If the old one
+// can't be deleted, the RotatedAccessKey is returned along with the error.
+func (c *Client) RotateAccessKey(
+	ctx context.Context,
+	sourceName string,
+	writeProfile ProfileKeyWriter,
+) (*RotatedAccessKey, error) {
+	source, err := c.authSource(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Credentials == nil {
+		return nil, errors.New("no credentials are configured for the authentication account")
+	}
+	oldCreds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if oldCreds.SessionToken != "" {
+		return nil, fmt.Errorf(
+			"the credentials for auth source '%s' are temporary - Only long-term access keys can be rotated", source.Name,
+		)
+	}
+	rotated := &RotatedAccessKey{OldAccessKeyID: oldCreds.AccessKeyID}
+	if !c.usesStoredAccessKey(source) {
+		if rotated.Profile, err = c.credentialsProfile(source, oldCreds); err != nil {
+			return nil, err
+		}
+		if writeProfile == nil {
+			return nil, fmt.Errorf("the access key for auth source '%s' is in a profile, which can't be updated", source.Name)
+		}
+	}
+
+	session, err := c.rotationSession(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	rotated.UserARN = session.SourceIdentity
+	sessionCfg, err := c.sourceConfig(ctx, source, awsconfig.WithCredentialsProvider(staticProvider(session)))
+	if err != nil {
+		return nil, err
+	}
+	iamClient := c.iamClient(sessionCfg)
+
+	created, err := iamClient.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "LimitExceeded" {
+		return nil, fmt.Errorf(
+			"unable to create a new access key, as IAM users can only have two - Delete the one that isn't %s: %w",
+			rotated.OldAccessKeyID, err,
+		)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to create a new access key: %w", err)
+	}
+	newKey := created.AccessKey
+	rotated.NewAccessKeyID = aws.ToString(newKey.AccessKeyId)
+	slog.Info("Created a new access key", "auth_source", source.Name, "access_key_id", rotated.NewAccessKeyID)
+
+	// rollback deletes the new access key, as something went wrong before it could replace the old one. It's deleted
+	// even if ctx has been cancelled (e.g. by Ctrl+C), so that it isn't left lying around.
+	rollback := func(cause error) error {
+		_, err := iamClient.DeleteAccessKey(context.WithoutCancel(ctx), &iam.DeleteAccessKeyInput{
+			AccessKeyId: newKey.AccessKeyId,
+		})
+		if err != nil {
+			return fmt.Errorf("%w (the new access key, %s, couldn't be deleted either: %s)", cause, rotated.NewAccessKeyID, err)
+		}
+		slog.Info("Deleted the new access key", "access_key_id", rotated.NewAccessKeyID)
+		return cause
+	}
+
+	secretAccessKey := aws.ToString(newKey.SecretAccessKey)
+	if err := c.verifyAccessKey(ctx, rotated.NewAccessKeyID, secretAccessKey, rotated.UserARN); err != nil {
+		return nil, rollback(err)
+	}
+	if rotated.Profile == "" {
+		err = c.putAccessKey(source, rotated.NewAccessKeyID, secretAccessKey, aws.ToTime(newKey.CreateDate))
+	} else {
+		err = writeProfile(rotated.Profile, rotated.NewAccessKeyID, secretAccessKey)
+	}
+	if err != nil {
+		err = fmt.Errorf("unable to save the new access key: %w", err)
+		if rotated.Profile != "" {
+			// The profile may have been partly written, so put the old access key back.
+			restoreErr := writeProfile(rotated.Profile, oldCreds.AccessKeyID, oldCreds.SecretAccessKey)
+			if restoreErr != nil {
+				err = fmt.Errorf("%w (and the old access key, %s, couldn't be put back in profile %s: %s)",
+					err, oldCreds.AccessKeyID, rotated.Profile, restoreErr)
+			}
+		}
+		return nil, rollback(err)
+	}
+	// The cached session was started with the old access key (and records it), so start a new one next time.
+	c.expireSession(source)
+
+	_, err = iamClient.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{AccessKeyId: aws.String(rotated.OldAccessKeyID)})
+	if err != nil {
+		return rotated, fmt.Errorf(
+			"the new access key is in use, but the old one (%s) couldn't be deleted - Delete it yourself: %w",
+			rotated.OldAccessKeyID, err,
+		)
+	}
+	return rotated, nil
+}
+
+// expireSession marks the session cached for source as expired, so that a new one is started the next time it's needed.
+func (c *Client) expireSession(source *config.AuthSource) {
+	if c.Cache == nil {
+		return
+	}
+	key := c.SessionCacheKey(source)
+	session, err := c.Cache.Get(key)
+	if err != nil || session == nil {
+		return
+	}
+	session.ExpiresAt = time.Now()
+	if err := c.Cache.Put(key, session); err != nil {
+		slog.Warn("Unable to expire the cached session", "auth_source", source.Name, "error", err)
+	}
+}
+
+// credentialsProfile returns the profile that creds (source's credentials) were loaded from, or an error if they
+// weren't loaded from a profile.
+func (c *Client) credentialsProfile(source *config.AuthSource, creds aws.Credentials) (string, error) {
+	if !strings.HasPrefix(creds.Source, "SharedConfigCredentials") {
+		return "", fmt.Errorf(
+			"the access key for auth source '%s' comes from %s, rather than a profile or a key backend, so roo can't "+
+				"replace it", source.Name, creds.Source,
+		)
+	}
+	if profile := c.profile(source); profile != "" {
+		return profile, nil
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile, nil
+	}
+	return "default", nil
+}
+
+// rotationSession returns an MFA session for source, which IAM will accept changes to access keys from.
+func (c *Client) rotationSession(
+	ctx context.Context,
+	source *config.AuthSource,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	if !source.MFASession {
+		// The auth source doesn't keep an MFA session, so get one just for this.
+		return c.getMFASession(ctx, source)
+	}
+	session, _, err := c.sessionProvider(source).Get(ctx)
+	return session, err
+}
+
+// verifyAccessKey checks that AWS accepts a new access key, and that it belongs to userARN.
+func (c *Client) verifyAccessKey(ctx context.Context, accessKeyID, secretAccessKey, userARN string) error {
+	provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey}, nil
+	})
+	for attempt := 1; ; attempt++ {
+		identity, err := c.CallerIdentity(ctx, provider)
+		if err == nil {
+			if arn := aws.ToString(identity.Arn); arn != userARN {
+				return fmt.Errorf("the new access key belongs to %s, rather than %s", arn, userARN)
+			}
+			return nil
+		}
+		var apiErr smithy.APIError
+		if attempt >= accessKeyVerifyAttempts || !errors.As(err, &apiErr) || apiErr.ErrorCode() != "InvalidClientTokenId" {
+			return fmt.Errorf("unable to verify the new access key: %w", err)
+		}
+		slog.Debug("Waiting for the new access key to be accepted", "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(accessKeyVerifyDelay):
+		}
+	}
+}
+
+// recordProfileAccessKey records the access key in source's profile (which session was issued with) in session, along
+// with when it was created, so that its age can be checked whenever the credentials are used. The creation date of
+// keys in a profile isn't recorded anywhere else, so it's looked up with IAM (using session) - unless the credentials
+// cached under cacheKey were issued with the same key, in which case their record is reused. Stored access keys are
+// checked when they're used instead.
+func (c *Client) recordProfileAccessKey(
+	ctx context.Context,
+	source *config.AuthSource,
+	cfg aws.Config,
+	session *cachedcredsprovider.CachedCredentials,
+	cacheKey string,
+) {
+	if source.AccessKeyMaxAge <= 0 || c.usesStoredAccessKey(source) {
+		return
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return
+	}
+	session.SourceAccessKeyID = creds.AccessKeyID
+	if c.Cache != nil {
+		previous, err := c.Cache.Get(cacheKey)
+		if err == nil && previous != nil && previous.SourceAccessKeyID == creds.AccessKeyID &&
+			!previous.SourceAccessKeyCreatedAt.IsZero() {
+			session.SourceAccessKeyCreatedAt = previous.SourceAccessKeyCreatedAt
+			return
+		}
+	}
+	sessionCfg := cfg.Copy()
+	sessionCfg.Credentials = aws.NewCredentialsCache(staticProvider(session))
+	output, err := c.iamClient(sessionCfg).ListAccessKeys(ctx, &iam.ListAccessKeysInput{})
+	if err != nil {
+		slog.Debug("Unable to check the age of the access key", "auth_source", source.Name, "error", err)
+		return
+	}
+	for _, key := range output.AccessKeyMetadata {
+		if aws.ToString(key.AccessKeyId) == creds.AccessKeyID {
+			session.SourceAccessKeyCreatedAt = aws.ToTime(key.CreateDate)
+		}
+	}
+}
+
+// warnAboutAccessKeyAge logs a warning if an access key for source, created at createdAt, is older than the auth
+// source's AccessKeyMaxAge.
+func warnAboutAccessKeyAge(source *config.AuthSource, accessKeyID string, createdAt time.Time) {
+	if source.AccessKeyMaxAge <= 0 || createdAt.IsZero() {
+		return
+	}
+	if age := time.Since(createdAt); age > source.AccessKeyMaxAge {
+		slog.Warn(
+			"The access key for the auth source is due to be rotated - Run 'roo auth rotate "+source.Name+"'",
+			"auth_source", source.Name,
+			"access_key_id", accessKeyID,
+			"age_days", int(age.Hours()/24),
+		)
+	}
+}
+
+// staticProvider returns a credentials provider for cached credentials.
+func staticProvider(cached *cachedcredsprovider.CachedCredentials) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return cached.AWSCredentials(), nil
+	})
+}
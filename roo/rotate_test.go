@@ -0,0 +1,229 @@
+package roo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+)
+
+// createAccessKeyResult returns the CreateAccessKey result for a successful call.
+func createAccessKeyResult(accessKeyID string) string {
+	return "<AccessKey><UserName>someone</UserName><AccessKeyId>" + accessKeyID + "</AccessKeyId>" +
+		"<Status>Active</Status><SecretAccessKey>new-secret</SecretAccessKey>" +
+		"<CreateDate>2026-01-02T03:04:05Z</CreateDate></AccessKey>"
+}
+
+// listAccessKeysResult returns the ListAccessKeys result for an IAM user with one access key, created at createDate.
+func listAccessKeysResult(accessKeyID, createDate string) string {
+	return "<AccessKeyMetadata><member><UserName>someone</UserName><AccessKeyId>" + accessKeyID + "</AccessKeyId>" +
+		"<Status>Active</Status><CreateDate>" + createDate + "</CreateDate></member></AccessKeyMetadata>" +
+		"<IsTruncated>false</IsTruncated>"
+}
+
+// rotationClient returns a client for an auth source with a stored access key, AKIAOLD, along with the fake STS (and
+// IAM) server that it uses. The fake deletes access keys successfully, recording which ones were deleted.
+func rotationClient(t *testing.T) (*Client, *fakeSTS, *[]string) {
+	t.Helper()
+	fake, server := newFakeSTS(t)
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		return sessionTokenResult("ASIASESSION"), "", ""
+	}
+	fake.actions["CreateAccessKey"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "ASIASESSION" {
+			t.Errorf("Expected the access key to be created with the MFA session, got %s", accessKeyID(r))
+		}
+		return createAccessKeyResult("AKIANEW"), "", ""
+	}
+	fake.actions["ListAccessKeys"] = func(r *http.Request) (string, string, string) {
+		return listAccessKeysResult("AKIAOLD", "2020-01-02T03:04:05Z"), "", ""
+	}
+	var deleted []string
+	fake.actions["DeleteAccessKey"] = func(r *http.Request) (string, string, string) {
+		deleted = append(deleted, r.Form.Get("AccessKeyId"))
+		return "", "", ""
+	}
+
+	conf := authSourceConfig()
+	conf.AuthSources[0].KeyBackend = "test"
+	conf.AuthSources[0].MFASerials = conf.AuthSources[0].MFASerials[:1]
+	client := New(conf, memoryCache{}, codes("111111"))
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+	store := cachedcredsprovider.NewMemoryStore()
+	client.OpenKeyStore = func(backend string) (cachedcredsprovider.Store, error) {
+		return store, nil
+	}
+	if err := client.StoreAccessKey(context.Background(), "corporate", "AKIAOLD", "old-secret"); err != nil {
+		t.Fatal(err)
+	}
+	return client, fake, &deleted
+}
+
+func TestRotateAccessKey(t *testing.T) {
+	client, fake, deleted := rotationClient(t)
+	rotated, err := client.RotateAccessKey(context.Background(), "corporate", nil)
+	if err != nil {
+		t.Fatalf("Unable to rotate the access key: %s", err)
+	}
+	if rotated.OldAccessKeyID != "AKIAOLD" || rotated.NewAccessKeyID != "AKIANEW" || rotated.Profile != "" ||
+		rotated.UserARN != "arn:aws:iam::999999999999:user/someone" {
+		t.Errorf("Unexpected result: %+v", rotated)
+	}
+	if len(*deleted) != 1 || (*deleted)[0] != "AKIAOLD" {
+		t.Errorf("Expected only the old access key to be deleted, got %v", *deleted)
+	}
+	if fake.calls["GetSessionToken"] != 1 {
+		t.Errorf("Expected one MFA session, got %d", fake.calls["GetSessionToken"])
+	}
+	// The MFA session was started with the old access key, so it mustn't be used again.
+	if session := client.Cache.(memoryCache)["session_corporate"]; session == nil || !session.IsExpired() {
+		t.Errorf("Expected the cached MFA session to be expired, got %+v", session)
+	}
+
+	stored, err := client.AccessKey("corporate")
+	if err != nil || stored == nil {
+		t.Fatalf("Unable to get the stored access key: %v", err)
+	}
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if stored.Values.AccessKeyID != "AKIANEW" || stored.Values.SecretAccessKey != "new-secret" ||
+		!stored.IssuedAt.Equal(createdAt) {
+		t.Errorf("Expected the new access key to be stored, got %+v", stored)
+	}
+}
+
+func TestRotateAccessKeyRollsBack(t *testing.T) {
+	client, fake, deleted := rotationClient(t)
+	fake.actions["GetCallerIdentity"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) == "AKIANEW" {
+			return "", "InvalidClientTokenId", "The security token included in the request is invalid."
+		}
+		return "<Arn>arn:aws:iam::999999999999:user/someone</Arn><Account>999999999999</Account>", "", ""
+	}
+	attempts, delay := accessKeyVerifyAttempts, accessKeyVerifyDelay
+	accessKeyVerifyAttempts, accessKeyVerifyDelay = 2, 0
+	t.Cleanup(func() {
+		accessKeyVerifyAttempts, accessKeyVerifyDelay = attempts, delay
+	})
+
+	if _, err := client.RotateAccessKey(context.Background(), "corporate", nil); err == nil {
+		t.Fatal("Expected an error when the new access key isn't accepted")
+	}
+	if len(*deleted) != 1 || (*deleted)[0] != "AKIANEW" {
+		t.Errorf("Expected the new access key to be deleted, got %v", *deleted)
+	}
+	if stored, err := client.AccessKey("corporate"); err != nil || stored.Values.AccessKeyID != "AKIAOLD" {
+		t.Errorf("Expected the old access key to still be stored, got %+v (error: %v)", stored, err)
+	}
+}
+
+func TestRotateAccessKeyInProfile(t *testing.T) {
+	client, _, deleted := rotationClient(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	credentials := "[base]\naws_access_key_id = AKIAOLD\naws_secret_access_key = old-secret\n"
+	if err := os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client.Config.AuthSources[0] = config.AuthSource{
+		Name:       "corporate",
+		Profile:    "base",
+		MFASerials: []string{"arn:aws:iam::000000000000:mfa/primary"},
+	}
+
+	var written []string
+	rotated, err := client.RotateAccessKey(
+		context.Background(),
+		"corporate",
+		func(profile, accessKeyID, secretAccessKey string) error {
+			written = append(written, profile, accessKeyID, secretAccessKey)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Unable to rotate the access key: %s", err)
+	}
+	if rotated.Profile != "base" || len(written) != 3 || written[0] != "base" || written[1] != "AKIANEW" {
+		t.Errorf("Expected the new access key to be written to the profile, got %+v and %v", rotated, written)
+	}
+	if len(*deleted) != 1 || (*deleted)[0] != "AKIAOLD" {
+		t.Errorf("Expected the old access key to be deleted, got %v", *deleted)
+	}
+}
+
+func TestProfileAccessKeyAgeIsCached(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["GetSessionToken"] = func(r *http.Request) (string, string, string) {
+		return sessionTokenResult("ASIASESSION"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+	fake.actions["ListAccessKeys"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "ASIASESSION" {
+			t.Errorf("Expected the access keys to be listed with the MFA session, got %s", accessKeyID(r))
+		}
+		return listAccessKeysResult("AKIAFAKE", "2020-01-02T03:04:05Z"), "", ""
+	}
+	conf := authSourceConfig()
+	conf.AuthSources[0].MFASerials = conf.AuthSources[0].MFASerials[:1]
+	conf.AuthSources[0].AccessKeyMaxAge = 90 * 24 * time.Hour
+	cache := memoryCache{}
+	client := New(conf, cache, codes("111111"))
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+
+	// The key's creation date is looked up once, and kept with the MFA session and each role's credentials, so it can
+	// be checked when they're used from the cache.
+	for _, role := range []string{"prod-readonly", "test-developer", "prod-readonly"} {
+		if _, err := client.Credentials(context.Background(), role); err != nil {
+			t.Fatalf("Unable to get credentials for %s: %s", role, err)
+		}
+	}
+	if fake.calls["ListAccessKeys"] != 1 {
+		t.Errorf("Expected the access keys to be listed once, got %d", fake.calls["ListAccessKeys"])
+	}
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for key, cached := range cache {
+		if cached.SourceAccessKeyID != "AKIAFAKE" || !cached.SourceAccessKeyCreatedAt.Equal(createdAt) {
+			t.Errorf("Expected %s to record the access key's creation date, got %s created %s",
+				key, cached.SourceAccessKeyID, cached.SourceAccessKeyCreatedAt)
+		}
+	}
+}
+
+func TestRotateAccessKeyReportsFailedRestore(t *testing.T) {
+	client, _, deleted := rotationClient(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	credentials := "[base]\naws_access_key_id = AKIAOLD\naws_secret_access_key = old-secret\n"
+	if err := os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client.Config.AuthSources[0] = config.AuthSource{
+		Name:       "corporate",
+		Profile:    "base",
+		MFASerials: []string{"arn:aws:iam::000000000000:mfa/primary"},
+	}
+
+	// Neither the new access key nor the old one can be written to the profile.
+	_, err := client.RotateAccessKey(
+		context.Background(),
+		"corporate",
+		func(profile, accessKeyID, secretAccessKey string) error {
+			return errors.New("disk full")
+		},
+	)
+	if err == nil || !strings.Contains(err.Error(), "couldn't be put back in profile") {
+		t.Errorf("Expected an error about restoring the old access key, got %v", err)
+	}
+	if len(*deleted) != 1 || (*deleted)[0] != "AKIANEW" {
+		t.Errorf("Expected the new access key to be deleted, got %v", *deleted)
+	}
+}
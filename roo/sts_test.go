@@ -0,0 +1,79 @@
+package roo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSTS is a stand-in for the STS API. Each action is handled by the function registered for it, which returns the
+// XML result element (or an error code and message).
+type fakeSTS struct {
+	t       *testing.T
+	actions map[string]func(r *http.Request) (result string, errCode string, errMessage string)
+	calls   map[string]int
+	// skew is how far ahead of the server the local clock appears to be, going by the Date header.
+	skew time.Duration
+}
+
+// newFakeSTS starts a fake STS server, and points the AWS SDK's credential and config loading at dummy values so that
+// tests don't depend on the environment.
+func newFakeSTS(t *testing.T) (*fakeSTS, *httptest.Server) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAFAKE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "fake")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_PROFILE", "")
+
+	fake := &fakeSTS{
+		t:       t,
+		actions: map[string]func(r *http.Request) (string, string, string){},
+		calls:   map[string]int{},
+	}
+	fake.actions["GetCallerIdentity"] = func(r *http.Request) (string, string, string) {
+		return "<Arn>arn:aws:iam::999999999999:user/someone</Arn><UserId>AIDAFAKE</UserId>" +
+			"<Account>999999999999</Account>", "", ""
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.t.Errorf("Unable to parse STS request: %s", err)
+	}
+	action := r.Form.Get("Action")
+	f.calls[action]++
+	handler, ok := f.actions[action]
+	if !ok {
+		f.t.Errorf("Unexpected STS action: %s", action)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	result, errCode, errMessage := handler(r)
+	w.Header().Set("Content-Type", "text/xml")
+	w.Header().Set("Date", time.Now().Add(-f.skew).UTC().Format(http.TimeFormat))
+	if errCode != "" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><Error><Type>Sender</Type>`+
+			`<Code>%s</Code><Message>%s</Message></Error><RequestId>fake</RequestId></ErrorResponse>`,
+			errCode, errMessage)
+		return
+	}
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult>%[2]s</%[1]sResult>`+
+		`<ResponseMetadata><RequestId>fake</RequestId></ResponseMetadata></%[1]sResponse>`, action, result)
+}
+
+// assumeRoleResult returns the AssumeRole result for a successful call.
+func assumeRoleResult(accessKeyID string) string {
+	return "<Credentials><AccessKeyId>" + accessKeyID + "</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>" +
+		"<SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>" +
+		"<AssumedRoleUser><Arn>arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1</Arn>" +
+		"<AssumedRoleId>AROAFAKE:roo-1</AssumedRoleId></AssumedRoleUser>"
+}
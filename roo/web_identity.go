@@ -0,0 +1,128 @@
+package roo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/util"
+)
+
+// Environment variables that web_identity auth sources fall back to, as set by EKS (and used by the AWS SDKs).
+const (
+	envWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	envRoleARN              = "AWS_ROLE_ARN"
+)
+
+// getWebIdentitySession exchanges the OIDC token for source for credentials for its role, with
+// AssumeRoleWithWebIdentity. Roles are then assumed with those credentials, as they would be with an MFA session.
+func (c *Client) getWebIdentitySession(
+	ctx context.Context,
+	source *config.AuthSource,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	roleARN := webIdentityRoleARN(source)
+	if roleARN == "" {
+		return nil, fmt.Errorf("auth source '%s' doesn't have a role_arn, and %s isn't set", source.Name, envRoleARN)
+	}
+	token, err := webIdentityToken(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	// The request isn't signed, so the config is only needed for the region and network settings.
+	cfg, err := c.sourceConfig(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(roleARN),
+		RoleSessionName:  aws.String("roo-" + strconv.FormatInt(time.Now().UnixNano(), 10)),
+		WebIdentityToken: aws.String(token),
+	}
+	if source.SessionDuration > 0 {
+		input.DurationSeconds = aws.Int32(int32(source.SessionDuration.Seconds()))
+	}
+	output, err := c.stsClient(cfg).AssumeRoleWithWebIdentity(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to assume role %s with a web identity token: %w", roleARN, err)
+	}
+	slog.Info("Started a new web identity session", "auth_source", source.Name, "role_arn", roleARN,
+		"expires_at", aws.ToTime(output.Credentials.Expiration))
+	cached := cachedcredsprovider.NewCachedCredentialsFromWebIdentity(output)
+	cached.RoleARN = roleARN
+	return cached, nil
+}
+
+// webIdentityRoleCredentials returns the credentials for the role that source's web identity token is exchanged for,
+// from its session.
+func (c *Client) webIdentityRoleCredentials(
+	ctx context.Context,
+	source *config.AuthSource,
+	refreshSession bool,
+) (*cachedcredsprovider.CachedCredentials, error) {
+	session := c.sessionProvider(source)
+	if refreshSession {
+		session.Expire()
+	}
+	sessionCredentials, _, err := session.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cached := *sessionCredentials
+	return &cached, nil
+}
+
+// webIdentityRoleARN returns the role that source's OIDC token is exchanged for.
+func webIdentityRoleARN(source *config.AuthSource) string {
+	if source.RoleARN != "" {
+		return source.RoleARN
+	}
+	return os.Getenv(envRoleARN)
+}
+
+// webIdentityToken returns the OIDC token for source.
+func webIdentityToken(ctx context.Context, source *config.AuthSource) (string, error) {
+	if source.WebIdentityTokenCommand != "" {
+		return webIdentityTokenFromCommand(ctx, source.WebIdentityTokenCommand)
+	}
+	tokenFile := source.WebIdentityTokenFile
+	if tokenFile == "" {
+		tokenFile = os.Getenv(envWebIdentityTokenFile)
+	}
+	if tokenFile == "" {
+		return "", fmt.Errorf(
+			"auth source '%s' doesn't have a web_identity_token_file or web_identity_token_command, and %s isn't set",
+			source.Name, envWebIdentityTokenFile,
+		)
+	}
+	// The file is read every time, as CI systems and Kubernetes replace the token before it expires.
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the web identity token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("the web identity token file, %s, is empty", tokenFile)
+	}
+	return token, nil
+}
+
+// webIdentityTokenFromCommand runs command with the shell, and returns the OIDC token that it prints.
+func webIdentityTokenFromCommand(ctx context.Context, command string) (string, error) {
+	token, err := util.RunCommand(ctx, command)
+	if err != nil {
+		return "", fmt.Errorf("unable to get a web identity token from '%s': %w", command, err)
+	}
+	if token == "" {
+		return "", fmt.Errorf("'%s' didn't print a web identity token", command)
+	}
+	return token, nil
+}
@@ -0,0 +1,217 @@
+package roo
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jkueh/roo/config"
+)
+
+// ciRoleARN is the role that the web identity token is exchanged for in these tests.
+const ciRoleARN = "arn:aws:iam::000000000000:role/CI"
+
+// signedJWT returns a JWT with the given claims, signed with key using RS256 - As CI systems sign their OIDC tokens.
+func signedJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// verifiedJWTSubject checks token's signature against key, returning its subject, or an empty string if the signature
+// isn't valid.
+func verifiedJWTSubject(key *rsa.PublicKey, token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ""
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// webIdentityResult returns the AssumeRoleWithWebIdentity result for a successful call.
+func webIdentityResult(accessKeyID, subject string) string {
+	return "<Credentials><AccessKeyId>" + accessKeyID + "</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>" +
+		"<SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>" +
+		"<SubjectFromWebIdentityToken>" + subject + "</SubjectFromWebIdentityToken>" +
+		"<AssumedRoleUser><Arn>arn:aws:sts::000000000000:assumed-role/CI/roo-1</Arn>" +
+		"<AssumedRoleId>AROAFAKE:roo-1</AssumedRoleId></AssumedRoleUser>"
+}
+
+func TestAssumeRoleWithWebIdentity(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRoleWithWebIdentity"] = func(r *http.Request) (string, string, string) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Expected AssumeRoleWithWebIdentity not to be signed")
+		}
+		if r.Form.Get("RoleArn") != ciRoleARN {
+			t.Errorf("Unexpected role: %s", r.Form.Get("RoleArn"))
+		}
+		subject := verifiedJWTSubject(&key.PublicKey, r.Form.Get("WebIdentityToken"))
+		if subject == "" {
+			return "", "InvalidIdentityToken", "Couldn't verify the signature of the token."
+		}
+		return webIdentityResult("ASIAWEBIDENTITY", subject), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "ASIAWEBIDENTITY" || r.Form.Get("TokenCode") != "" {
+			t.Errorf("Expected the role to be assumed with the web identity session, got %s", accessKeyID(r))
+		}
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	token := signedJWT(t, key, map[string]any{"sub": "repo:example/infra:ref:refs/heads/main", "aud": "sts.amazonaws.com"})
+	if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf := authSourceConfig()
+	conf.AuthSources = []config.AuthSource{{
+		Name:                 "ci",
+		Type:                 config.AuthSourceTypeWebIdentity,
+		Default:              true,
+		RoleARN:              ciRoleARN,
+		WebIdentityTokenFile: tokenFile,
+	}}
+	conf.Roles = append(conf.Roles, config.RoleConfig{Name: "ci", ARN: ciRoleARN})
+	cache := memoryCache{}
+	// No MFA provider is needed.
+	client := New(conf, cache, nil)
+	client.STSEndpoint = server.URL
+
+	for _, role := range []string{"prod-readonly", "test-developer"} {
+		creds, err := client.Credentials(context.Background(), role)
+		if err != nil {
+			t.Fatalf("Unable to assume %s with the web identity token: %s", role, err)
+		}
+		if creds.AccessKeyID != "ASIAROLE" {
+			t.Errorf("Unexpected credentials for %s: %+v", role, creds)
+		}
+	}
+	if fake.calls["AssumeRoleWithWebIdentity"] != 1 || fake.calls["AssumeRole"] != 2 {
+		t.Errorf("Expected one web identity session for both roles, got %v", fake.calls)
+	}
+	session := cache["session_ci"]
+	if session == nil || session.RoleARN != ciRoleARN ||
+		session.SourceIdentity != "repo:example/infra:ref:refs/heads/main" {
+		t.Errorf("Expected the web identity session to be cached, got %+v", session)
+	}
+
+	// The role that the token is exchanged for doesn't need assuming again.
+	creds, err := client.Credentials(context.Background(), "ci")
+	if err != nil || creds.AccessKeyID != "ASIAWEBIDENTITY" {
+		t.Errorf("Expected the web identity session's credentials for its own role, got %+v (error: %v)", creds, err)
+	}
+	if fake.calls["AssumeRole"] != 2 {
+		t.Errorf("Expected the web identity role not to be assumed again, got %d calls", fake.calls["AssumeRole"])
+	}
+
+	// Tokens that weren't signed by the trusted key are rejected.
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokenFile, []byte(signedJWT(t, otherKey, map[string]any{"sub": "me"})), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BaseCredentials(context.Background(), "ci"); err == nil ||
+		!strings.Contains(err.Error(), "InvalidIdentityToken") {
+		t.Errorf("Expected the token to be rejected, got %v", err)
+	}
+}
+
+func TestWebIdentityTokenFromCommand(t *testing.T) {
+	source := &config.AuthSource{Name: "ci", WebIdentityTokenCommand: "echo header.payload.signature"}
+	token, err := webIdentityToken(context.Background(), source)
+	if err != nil || token != "header.payload.signature" {
+		t.Errorf("Expected the token printed by the command, got %q (error: %v)", token, err)
+	}
+
+	source.WebIdentityTokenCommand = "echo 'no token for you' >&2; exit 1"
+	if _, err := webIdentityToken(context.Background(), source); err == nil ||
+		!strings.Contains(err.Error(), "no token for you") {
+		t.Errorf("Expected the command's error output in the error, got %v", err)
+	}
+
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	if _, err := webIdentityToken(context.Background(), &config.AuthSource{Name: "ci"}); err == nil {
+		t.Error("Expected an error when there's nowhere to get a token from")
+	}
+}
+
+func TestAuthSourceOverride(t *testing.T) {
+	fake, server := newFakeSTS(t)
+	fake.actions["AssumeRoleWithWebIdentity"] = func(r *http.Request) (string, string, string) {
+		return webIdentityResult("ASIAWEBIDENTITY", "repo:example/infra:ref:refs/heads/main"), "", ""
+	}
+	fake.actions["AssumeRole"] = func(r *http.Request) (string, string, string) {
+		if accessKeyID(r) != "ASIAWEBIDENTITY" {
+			t.Errorf("Expected the role to be assumed with the web identity session, got %s", accessKeyID(r))
+		}
+		return assumeRoleResult("ASIAROLE"), "", ""
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("header.payload.signature"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf := authSourceConfig()
+	// The corporate auth source stays the default, and would need an MFA code.
+	conf.AuthSources = append(conf.AuthSources, config.AuthSource{
+		Name:                 "ci",
+		Type:                 config.AuthSourceTypeWebIdentity,
+		RoleARN:              ciRoleARN,
+		WebIdentityTokenFile: tokenFile,
+	})
+	client := New(conf, memoryCache{}, nil)
+	client.STSEndpoint = server.URL
+	client.AuthSource = "ci"
+
+	creds, err := client.Credentials(context.Background(), "prod-readonly")
+	if err != nil || creds.AccessKeyID != "ASIAROLE" {
+		t.Errorf("Expected the role to be assumed from the overriding auth source, got %+v (error: %v)", creds, err)
+	}
+	if fake.calls["GetSessionToken"] != 0 {
+		t.Error("Expected the default auth source not to be used")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// exitPromptRequired is the exit code used when roo needs to prompt the user, but can't - either because -no-prompt
+// was given, or because there's no terminal to prompt on. Scripts can use it to tell this apart from other failures.
+const exitPromptRequired = 3
+
+// noPrompt disables prompting entirely (-no-prompt).
+var noPrompt bool
+
+var (
+	// errPromptDisabled is returned when roo would need to prompt, but -no-prompt was given.
+	errPromptDisabled = errors.New("roo needs to prompt, but -no-prompt was given")
+	// errNoTerminal is returned when roo would need to prompt, but there's no terminal to prompt on.
+	errNoTerminal = errors.New("roo needs to prompt, but there's no terminal to prompt on")
+)
+
+// getTerminalState and restoreTerminalState save and restore the terminal's settings (e.g. whether input is echoed).
+// They're variables so that tests can stand in for a real terminal.
+var (
+	getTerminalState     = term.GetState
+	restoreTerminalState = term.Restore
+)
+
+// terminal is the controlling terminal, which prompts are read from and written to. Stdin is deliberately never used
+// for prompts, as it belongs to the command that roo runs (e.g. 'cat plan.json | roo aws ... file:///dev/stdin').
+type terminal struct {
+	in  *os.File
+	out *os.File
+}
+
+// openTerminal opens the controlling terminal, returning errPromptDisabled or errNoTerminal if it can't be used.
+func openTerminal() (*terminal, error) {
+	if noPrompt {
+		return nil, errPromptDisabled
+	}
+	in, err := os.OpenFile(terminalInputDevice, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errNoTerminal, err)
+	}
+	if !isTerminal(in) {
+		in.Close()
+		return nil, errNoTerminal
+	}
+	if terminalOutputDevice == terminalInputDevice {
+		return &terminal{in: in, out: in}, nil
+	}
+	out, err := os.OpenFile(terminalOutputDevice, os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("%w: %s", errNoTerminal, err)
+	}
+	return &terminal{in: in, out: out}, nil
+}
+
+// Close closes the terminal.
+func (t *terminal) Close() error {
+	if t.out != t.in {
+		t.out.Close()
+	}
+	return t.in.Close()
+}
+
+// readLine prints prompt, then returns the line that the user enters (without the line ending).
+func (t *terminal) readLine(prompt string) (string, error) {
+	fmt.Fprint(t.out, prompt+": ")
+	text, err := bufio.NewReader(t.in).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(text, "\r\n"), nil
+}
+
+// readSecret is like readLine, but doesn't echo what the user types.
+func (t *terminal) readSecret(prompt string) (string, error) {
+	fmt.Fprint(t.out, prompt+": ")
+	secret, err := term.ReadPassword(int(t.in.Fd()))
+	fmt.Fprintln(t.out)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// readWithContext calls read (e.g. a readSecret call) in the background, so that it can give up if ctx is cancelled
+// (e.g. on Ctrl-C). If it does give up, the terminal's settings are restored - read may have turned echo off, and won't
+// get the chance to turn it back on before roo exits.
+func (t *terminal) readWithContext(ctx context.Context, read func() (string, error)) (string, error) {
+	state, err := getTerminalState(int(t.in.Fd()))
+	if err != nil {
+		return "", err
+	}
+	type result struct {
+		text string
+		err  error
+	}
+	input := make(chan result, 1)
+	go func() {
+		text, err := read()
+		input <- result{text, err}
+	}()
+	select {
+	case <-ctx.Done():
+		if err := restoreTerminalState(int(t.in.Fd()), state); err != nil {
+			slog.Warn("Unable to restore the terminal's settings - Run 'stty sane' if it doesn't echo", "error", err)
+		}
+		fmt.Fprintln(t.out)
+		return "", ctx.Err()
+	case r := <-input:
+		return r.text, r.err
+	}
+}
+
+// isTerminal returns true if f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// isPromptError reports whether err was caused by roo being unable to prompt the user.
+func isPromptError(err error) bool {
+	return errors.Is(err, errPromptDisabled) || errors.Is(err, errNoTerminal)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// The device files for the controlling terminal.
+const (
+	terminalInputDevice  = "/dev/tty"
+	terminalOutputDevice = "/dev/tty"
+)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/term"
+)
+
+// fakeTerminalState stands in for saving and restoring a real terminal's settings, for the rest of the test. It returns
+// the state that's "saved", and where the state that's restored is recorded.
+func fakeTerminalState(t *testing.T) (*term.State, **term.State) {
+	saved := &term.State{}
+	var restored *term.State
+	getTerminalState = func(fd int) (*term.State, error) { return saved, nil }
+	restoreTerminalState = func(fd int, state *term.State) error {
+		restored = state
+		return nil
+	}
+	t.Cleanup(func() {
+		getTerminalState, restoreTerminalState = term.GetState, term.Restore
+	})
+	return saved, &restored
+}
+
+func TestReadWithContextRestoresTerminal(t *testing.T) {
+	in, err := os.CreateTemp(t.TempDir(), "tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(t.TempDir(), "tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	tty := &terminal{in: in, out: out}
+
+	saved, restored := fakeTerminalState(t)
+
+	// A read that's answered leaves the terminal alone.
+	text, err := tty.readWithContext(context.Background(), func() (string, error) { return "123456", nil })
+	if err != nil || text != "123456" {
+		t.Errorf("Expected the text that was read, got %q (error: %v)", text, err)
+	}
+	if *restored != nil {
+		t.Error("Expected the terminal not to be restored after a read")
+	}
+
+	// A read that's cancelled (e.g. with Ctrl-C, while echo is off) restores the terminal.
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	reading := make(chan struct{})
+	go func() {
+		<-reading
+		cancel()
+	}()
+	_, err = tty.readWithContext(ctx, func() (string, error) {
+		close(reading)
+		<-release
+		return "", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the read to be cancelled, got %v", err)
+	}
+	if *restored != saved {
+		t.Error("Expected the terminal's saved state to be restored")
+	}
+}
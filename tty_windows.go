@@ -0,0 +1,7 @@
+package main
+
+// The device files for the console.
+const (
+	terminalInputDevice  = "CONIN$"
+	terminalOutputDevice = "CONOUT$"
+)
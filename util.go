@@ -1,31 +1,124 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+	"github.com/jkueh/roo/util"
 )
 
-func getStringInputFromUser(prompt string) string {
-	fmt.Print(prompt + ": ")
-	text, err := bufio.NewReader(os.Stdin).ReadString('\n')
+// mfaPrompt returns an MFA provider that prompts the user (on the terminal, without echoing) for the current code from
+// their MFA device, giving them a few attempts to enter one that's the right length. If the device belongs to an auth
+// source with the command code source, its command is run instead.
+func mfaPrompt(conf *config.Config) roo.MFAProviderFunc {
+	return func(ctx context.Context, serial string) (string, error) {
+		source := conf.AuthSourceForMFADevice(serial)
+		if source != nil && source.MFACodeSource == config.MFACodeSourceCommand {
+			return mfaCodeFromCommand(ctx, source.MFACodeCommand, serial, conf.GetMFACodeLength())
+		}
+		return promptForMFACode(ctx, conf.GetMFACodeLength())
+	}
+}
+
+// mfaCodeFromCommand runs command with the shell, and returns the MFA code that it prints. The serial of the MFA device
+// is passed to the command in ROO_MFA_SERIAL.
+func mfaCodeFromCommand(ctx context.Context, command, serial string, length int) (string, error) {
+	oneTimePasscode, err := util.RunCommand(ctx, command, "ROO_MFA_SERIAL="+serial)
 	if err != nil {
-		log.Fatalln("An error occurred while trying to get user input:", err)
+		return "", fmt.Errorf("unable to get an MFA code from '%s': %w", command, err)
+	}
+	if _, err := oneTimePasscodeIsValid(oneTimePasscode, length); err != nil {
+		return "", fmt.Errorf("'%s' didn't print a valid MFA code: %w", command, err)
 	}
-	return strings.TrimSuffix(text, "\n")
+	return oneTimePasscode, nil
 }
 
-func oneTimePasscodeIsValid(code string) (bool, error) {
-	// Step 0 - It must be at least 6 characters
-	if len(code) < 6 {
-		return false, fmt.Errorf("one-time passcode provided was less than 6 characters long")
+// selectMFADevice asks the user (on the terminal) which of serials - the MFA devices for an auth source - they want to
+// use. If roo can't prompt, the first device is used.
+func selectMFADevice(ctx context.Context, source string, serials []string) (string, error) {
+	tty, err := openTerminal()
+	if isPromptError(err) {
+		slog.Info("Unable to ask which MFA device to use - Using the first one", "serial", serials[0], "error", err)
+		return serials[0], nil
+	} else if err != nil {
+		return "", err
 	}
-	_, err := strconv.Atoi(code)
+	defer tty.Close()
+
+	if source == "" {
+		fmt.Fprintln(tty.out, "Which MFA device do you want to use?")
+	} else {
+		fmt.Fprintf(tty.out, "Which MFA device do you want to use for %s?\n", source)
+	}
+	for i, serial := range serials {
+		fmt.Fprintf(tty.out, "  %d) %s\n", i+1, serial)
+	}
+	for {
+		answer, err := tty.readLine(fmt.Sprintf("MFA device [1-%d, default 1]", len(serials)))
+		if err != nil {
+			return "", err
+		}
+		if answer = strings.TrimSpace(answer); answer == "" {
+			return serials[0], nil
+		}
+		if choice, err := strconv.Atoi(answer); err == nil && choice >= 1 && choice <= len(serials) {
+			return serials[choice-1], nil
+		}
+		fmt.Fprintln(tty.out, "Please enter the number of one of the devices above.")
+	}
+}
+
+// promptForMFACode prompts once for an MFA code of the given length. If the code isn't valid, the error wraps
+// roo.ErrMFACodeRejected, so that the client asks again (up to mfa_attempts times).
+func promptForMFACode(ctx context.Context, length int) (string, error) {
+	tty, err := openTerminal()
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("unable to prompt for an MFA code (use -code to provide one): %w", err)
+	}
+	defer tty.Close()
+
+	oneTimePasscode, err := tty.readWithContext(ctx, func() (string, error) {
+		return tty.readSecret("MFA Code")
+	})
+	if err != nil && ctx.Err() != nil {
+		return "", err
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read MFA code: %w", err)
+	}
+	slog.Debug("MFA code provided", "otp", oneTimePasscode)
+
+	if _, err := oneTimePasscodeIsValid(oneTimePasscode, length); err != nil {
+		return "", fmt.Errorf("%w: %w", roo.ErrMFACodeRejected, err)
+	}
+	return oneTimePasscode, nil
+}
+
+// oneTimePasscodeIsValid checks that code is made up of exactly length digits.
+func oneTimePasscodeIsValid(code string, length int) (bool, error) {
+	if len(code) != length {
+		return false, fmt.Errorf(
+			"one-time passcode must be %d digits long (See mfa_code_length in %s), but was %d",
+			length, configFile, len(code),
+		)
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false, fmt.Errorf("one-time passcode must only contain digits")
+		}
 	}
 	return true, nil
 }
+
+// getEnvOrDefault returns the value of the environment variable named by key, or defaultValue if it's unset or empty.
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -1,20 +1,31 @@
 package util
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"runtime"
+	"strings"
 )
 
-// EnsureDirExists will create a directory if it doesn't exist.
+// EnsureDirExists will create a directory if it doesn't exist, or set its permissions to fileMode if it does.
 func EnsureDirExists(dirPath string, fileMode os.FileMode) error {
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
+	info, err := os.Stat(dirPath)
+	if os.IsNotExist(err) {
 		return os.MkdirAll(dirPath, fileMode)
+	} else if err != nil {
+		return err
 	}
-	if runtime.GOOS != "windows" { // We skip the chmod step for Windows... Because we can't chmod.
+	if runtime.GOOS == "windows" { // We skip the chmod step for Windows... Because we can't chmod.
+		return nil
+	}
+	if info.Mode().Perm() == fileMode.Perm() {
 		return nil
 	}
-	// This won't return an error if we're setting it to 0700 - which we do in init.go, but for consistency with the other
-	// chmod operations we do, we remove it from the code path.
 	return os.Chmod(dirPath, fileMode)
 }
 
@@ -29,3 +40,26 @@ func EnsureFileExists(filePath string, fileMode os.FileMode) error {
 	}
 	return os.Chmod(filePath, fileMode)
 }
+
+// RunCommand runs command with the shell ('sh -c', or 'cmd /C' on Windows), with env added to its environment, and
+// returns what it prints with surrounding whitespace trimmed. What it prints to stderr is passed through (e.g. so that
+// the user sees a prompt to touch their security key), and included in the error if it fails.
+func RunCommand(ctx context.Context, command string, env ...string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && stderr.Len() > 0 {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
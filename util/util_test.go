@@ -0,0 +1,47 @@
+package util
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEnsureDirExists(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "roo")
+	if err := EnsureDirExists(dir, 0700); err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("Directory wasn't created: %v", err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureDirExists(dir, 0700); err != nil {
+		t.Fatalf("Unable to set directory permissions: %s", err)
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected permissions of an existing directory to be reset to 0700, got %o", info.Mode().Perm())
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test commands need a POSIX shell")
+	}
+	output, err := RunCommand(context.Background(), `echo "  $GREETING  "`, "GREETING=hello")
+	if err != nil || output != "hello" {
+		t.Errorf("Expected the trimmed output of the command, got %q (error: %v)", output, err)
+	}
+	if _, err := RunCommand(context.Background(), "echo 'no luck' >&2; exit 1"); err == nil ||
+		!strings.Contains(err.Error(), "no luck") {
+		t.Errorf("Expected the command's error output in the error, got %v", err)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+)
 
 func TestEmptyOTP(t *testing.T) {
-	if valid, err := oneTimePasscodeIsValid(""); err == nil {
+	if valid, err := oneTimePasscodeIsValid("", 6); err == nil {
 		t.Errorf("Empty OTP triggered did not trigger error as expected: %s", err)
 	} else if valid {
 		t.Errorf("Empty OTP passed validation")
@@ -11,7 +16,15 @@ func TestEmptyOTP(t *testing.T) {
 }
 
 func TestAlphabetOTP(t *testing.T) {
-	if valid, err := oneTimePasscodeIsValid("hunter2"); err == nil {
+	if valid, err := oneTimePasscodeIsValid("hunter2", 6); err == nil {
+		t.Errorf("Invalid (alphabetical) OTP did not trigger error as expected: %s", err)
+	} else if valid {
+		t.Errorf("Invalid (alphabetical) OTP passed validation")
+	}
+}
+
+func TestAlphabetOTPOfConfiguredLength(t *testing.T) {
+	if valid, err := oneTimePasscodeIsValid("hunter", 6); err == nil {
 		t.Errorf("Invalid (alphabetical) OTP did not trigger error as expected: %s", err)
 	} else if valid {
 		t.Errorf("Invalid (alphabetical) OTP passed validation")
@@ -19,7 +32,7 @@ func TestAlphabetOTP(t *testing.T) {
 }
 
 func TestShortOTP(t *testing.T) {
-	if valid, err := oneTimePasscodeIsValid("42069"); err == nil {
+	if valid, err := oneTimePasscodeIsValid("42069", 6); err == nil {
 		t.Errorf("Invalid (short) OTP did not trigger error as expected: %s", err)
 	} else if valid {
 		t.Errorf("Invalid (short) OTP passed validation")
@@ -27,9 +40,59 @@ func TestShortOTP(t *testing.T) {
 }
 
 func TestOTP(t *testing.T) {
-	if valid, err := oneTimePasscodeIsValid("054389"); err != nil {
+	if valid, err := oneTimePasscodeIsValid("054389", 6); err != nil {
 		t.Errorf("A valid OTP threw an error: %s", err)
 	} else if !valid {
 		t.Errorf("A valid OTP did not pass validation")
 	}
 }
+
+func TestShortOTPNamesSetting(t *testing.T) {
+	if _, err := oneTimePasscodeIsValid("42069", 6); err == nil || !strings.Contains(err.Error(), "mfa_code_length") {
+		t.Errorf("Error for a code of the wrong length doesn't mention mfa_code_length: %v", err)
+	}
+}
+
+func TestLongOTP(t *testing.T) {
+	if valid, err := oneTimePasscodeIsValid("0543891", 6); err == nil {
+		t.Errorf("Invalid (long) OTP did not trigger error as expected: %s", err)
+	} else if valid {
+		t.Errorf("Invalid (long) OTP passed validation")
+	}
+}
+
+func TestConfiguredLengthOTP(t *testing.T) {
+	if valid, err := oneTimePasscodeIsValid("05438912", 8); err != nil {
+		t.Errorf("A valid 8 digit OTP threw an error: %s", err)
+	} else if !valid {
+		t.Errorf("A valid 8 digit OTP did not pass validation")
+	}
+}
+
+func TestGetEnvOrDefault(t *testing.T) {
+	t.Setenv("ROO_TEST_VALUE", "")
+	if value := getEnvOrDefault("ROO_TEST_VALUE", "fallback"); value != "fallback" {
+		t.Errorf("Unset environment variable did not return the default value: %s", value)
+	}
+	t.Setenv("ROO_TEST_VALUE", "from-env")
+	if value := getEnvOrDefault("ROO_TEST_VALUE", "fallback"); value != "from-env" {
+		t.Errorf("Set environment variable did not override the default value: %s", value)
+	}
+}
+
+func TestMFACodeFromCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test commands need a POSIX shell")
+	}
+	serial := "arn:aws:iam::000000000000:mfa/someone"
+	code, err := mfaCodeFromCommand(context.Background(), `test "$ROO_MFA_SERIAL" = `+serial+` && echo 123456`, serial, 6)
+	if err != nil || code != "123456" {
+		t.Errorf("Expected the code printed by the command, got %q, %v", code, err)
+	}
+	if _, err := mfaCodeFromCommand(context.Background(), "echo not-a-code", serial, 6); err == nil {
+		t.Error("Expected an error for a command that doesn't print a valid code")
+	}
+	if _, err := mfaCodeFromCommand(context.Background(), "exit 1", serial, 6); err == nil {
+		t.Error("Expected an error for a command that fails")
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+// Where the credentials reported by 'roo whoami' came from.
+const (
+	whoamiSourceEnvironment = "environment"
+	whoamiSourceCache       = "cache"
+)
+
+// What the AWS_* environment variables in the current shell contain, as reported by 'roo whoami'.
+const (
+	environmentNone  = "none"
+	environmentRoo   = "roo"
+	environmentOther = "other"
+)
+
+// whoamiReport is the identity reported by 'roo whoami'.
+type whoamiReport struct {
+	// Source is where the credentials came from - The AWS_* environment variables, or roo's cache (with -role).
+	Source string `json:"source"`
+	// Environment is none if there aren't any credentials in the AWS_* environment variables, roo if they're for a
+	// session that roo issued (i.e. they're in its cache), and other if they aren't.
+	Environment string `json:"environment"`
+	// EnvironmentRole is the name of the role that the credentials in the environment are for, if they're from roo.
+	EnvironmentRole string `json:"environment_role,omitempty"`
+	Role            string `json:"role,omitempty"`
+	RoleARN         string `json:"role_arn,omitempty"`
+	SessionARN      string `json:"session_arn,omitempty"`
+	UserID          string `json:"user_id,omitempty"`
+	AccountID       string `json:"account_id,omitempty"`
+	AccountAlias    string `json:"account_alias,omitempty"`
+	RoleName        string `json:"role_name,omitempty"`
+	SessionName     string `json:"session_name,omitempty"`
+	CacheStatus     string `json:"cache_status"`
+	// SourceIdentity is the ARN of the identity that assumed the role.
+	SourceIdentity string     `json:"source_identity,omitempty"`
+	IssuedAt       *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	// TimeRemaining is the number of whole seconds until the credentials expire.
+	TimeRemaining int64 `json:"time_remaining_seconds,omitempty"`
+	// Verified is true if AWS accepted the credentials (with GetCallerIdentity).
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runWhoami implements 'roo whoami'.
+func runWhoami(args []string) int {
+	flags := flag.NewFlagSet("whoami", flag.ExitOnError)
+	addCommonFlags(flags)
+	var output, roleRef string
+	flags.StringVar(&output, "o", "text", "Output format: text or json.")
+	flags.StringVar(
+		&roleRef,
+		"role",
+		"",
+		"Report on the cached session for this role, rather than the credentials in the environment.",
+	)
+	parseCommonFlags(flags, args)
+	if output != "text" && output != "json" {
+		fmt.Fprintln(os.Stderr, "Unknown output format:", output)
+		flags.Usage()
+		return 2
+	}
+
+	conf := loadConfig()
+	store := openCredentialStore(conf)
+	client := roo.New(conf, store, nil)
+	applyNetworkConfig(client, conf)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, creds, err := findWhoamiCredentials(client, store, roleRef, os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if creds != nil {
+		verifyWhoami(ctx, client, report, *creds)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = writeWhoami(os.Stdout, report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "An error occurred while trying to write the report:", err)
+		return 1
+	}
+	if !report.Verified {
+		return 1
+	}
+	return 0
+}
+
+// findWhoamiCredentials works out which credentials 'roo whoami' should report on: those in the environment (as read
+// with getenv), unless roleRef is set or there aren't any, in which case it's the cached credentials for the role
+// (or the default role). The returned credentials are nil if there aren't any usable ones to verify.
+func findWhoamiCredentials(
+	client *roo.Client,
+	store cachedcredsprovider.Store,
+	roleRef string,
+	getenv func(string) string,
+) (*whoamiReport, *aws.Credentials, error) {
+	report := &whoamiReport{Environment: environmentNone, CacheStatus: cacheStatusNone}
+
+	var envCreds *aws.Credentials
+	if accessKeyID := getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		envCreds = &aws.Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    getenv("AWS_SESSION_TOKEN"),
+			Source:          whoamiSourceEnvironment,
+		}
+		report.Environment = environmentOther
+		if key, cached := findCachedCredentials(store, accessKeyID); cached != nil {
+			report.Environment = environmentRoo
+			report.EnvironmentRole = key
+			if role := roleForARN(client.Config, cached.RoleARN); role != nil {
+				report.EnvironmentRole = role.Name
+			}
+			if roleRef == "" {
+				report.Source = whoamiSourceEnvironment
+				addCachedCredentials(report, client.Config, cached)
+				return report, envCreds, nil
+			}
+		}
+	}
+	if roleRef == "" && envCreds != nil {
+		report.Source = whoamiSourceEnvironment
+		return report, envCreds, nil
+	}
+
+	report.Source = whoamiSourceCache
+	role, err := client.ResolveRole(roleRef)
+	if errors.Is(err, roo.ErrNoRole) {
+		return nil, nil, errors.New("there aren't any AWS credentials in the environment - Use -role to pick a role")
+	} else if err != nil {
+		return nil, nil, err
+	}
+	report.Role = role.Name
+	report.RoleARN = role.ARN
+	report.AccountID = role.AccountID()
+	cacheKey, err := roo.CacheKey(role)
+	if err != nil {
+		return nil, nil, err
+	}
+	cached, err := store.Get(cacheKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the cached credentials for %s: %w", role.Name, err)
+	}
+	if cached == nil {
+		report.Error = "there aren't any cached credentials for the role"
+		return report, nil, nil
+	}
+	addCachedCredentials(report, client.Config, cached)
+	if cached.IsExpired() {
+		report.Error = "the cached credentials for the role have expired"
+		return report, nil, nil
+	}
+	creds := cached.AWSCredentials()
+	return report, &creds, nil
+}
+
+// findCachedCredentials returns the cached role credentials (and their cache key) with the given access key ID, if any.
+// Auth source sessions are skipped, as they aren't roles.
+func findCachedCredentials(
+	store cachedcredsprovider.Store,
+	accessKeyID string,
+) (string, *cachedcredsprovider.CachedCredentials) {
+	keys, err := store.List()
+	if err != nil {
+		slog.Debug("Unable to list cached credentials", "error", err)
+		return "", nil
+	}
+	for _, key := range keys {
+		if roo.IsSessionCacheKey(key) {
+			continue
+		}
+		cached, err := store.Get(key)
+		if err != nil {
+			slog.Debug("Unable to read cached credentials", "key", key, "error", err)
+			continue
+		}
+		if cached != nil && cached.Values.AccessKeyID == accessKeyID {
+			return key, cached
+		}
+	}
+	return "", nil
+}
+
+// addCachedCredentials adds what roo knows about cached to report.
+func addCachedCredentials(report *whoamiReport, conf *config.Config, cached *cachedcredsprovider.CachedCredentials) {
+	if role := roleForARN(conf, cached.RoleARN); report.Role == "" && role != nil {
+		report.Role = role.Name
+	}
+	if cached.RoleARN != "" {
+		report.RoleARN = cached.RoleARN
+	}
+	report.SessionARN = cached.SessionARN
+	report.SourceIdentity = cached.SourceIdentity
+	if !cached.IssuedAt.IsZero() {
+		issuedAt := cached.IssuedAt
+		report.IssuedAt = &issuedAt
+	}
+	expiresAt := cached.ExpiresAt
+	report.ExpiresAt = &expiresAt
+	if cached.IsExpired() {
+		report.CacheStatus = cacheStatusExpired
+	} else {
+		report.CacheStatus = cacheStatusValid
+		report.TimeRemaining = int64(time.Until(expiresAt).Seconds())
+	}
+	report.RoleName, report.SessionName = parseAssumedRoleARN(report.SessionARN)
+	if report.AccountID == "" {
+		report.AccountID = (&config.RoleConfig{ARN: report.RoleARN}).AccountID()
+	}
+}
+
+// roleForARN returns the role in conf with the given ARN, or nil if there isn't one.
+func roleForARN(conf *config.Config, arn string) *config.RoleConfig {
+	for _, role := range conf.Roles {
+		if role.ARN == arn {
+			return &role
+		}
+	}
+	return nil
+}
+
+// verifyWhoami checks creds with GetCallerIdentity, adding the caller's details (and account alias) to report.
+func verifyWhoami(ctx context.Context, client *roo.Client, report *whoamiReport, creds aws.Credentials) {
+	provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return creds, nil
+	})
+	identity, err := client.CallerIdentity(ctx, provider)
+	if err != nil {
+		report.Error = err.Error()
+		return
+	}
+	report.Verified = true
+	report.SessionARN = aws.ToString(identity.Arn)
+	report.UserID = aws.ToString(identity.UserId)
+	report.AccountID = aws.ToString(identity.Account)
+	report.RoleName, report.SessionName = parseAssumedRoleARN(report.SessionARN)
+
+	alias, err := client.AccountAlias(ctx, provider)
+	if err != nil {
+		slog.Debug("Unable to get the account alias", "error", err)
+	}
+	if role := roleForARN(client.Config, report.RoleARN); alias == "" && role != nil {
+		// Fall back to the name that the config file gives the account.
+		alias = role.AccountName
+	}
+	report.AccountAlias = alias
+}
+
+// parseAssumedRoleARN returns the role and session names from an assumed role ARN, e.g.
+// arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1. Empty strings are returned for any other kind of ARN.
+func parseAssumedRoleARN(arn string) (roleName, sessionName string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" {
+		return "", ""
+	}
+	resource := strings.Split(parts[5], "/")
+	if len(resource) != 3 || resource[0] != "assumed-role" {
+		return "", ""
+	}
+	return resource[1], resource[2]
+}
+
+// writeWhoami writes report to w for humans.
+func writeWhoami(w io.Writer, report *whoamiReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+		}
+	}
+
+	account := report.AccountID
+	if report.AccountAlias != "" {
+		account += " (" + report.AccountAlias + ")"
+	}
+	environment := report.Environment
+	switch report.Environment {
+	case environmentRoo:
+		environment = "roo session for " + report.EnvironmentRole
+	case environmentOther:
+		environment = "credentials that weren't issued by roo"
+	}
+	var expiresAt string
+	if report.ExpiresAt != nil {
+		expiresAt = report.ExpiresAt.Local().Format(time.RFC3339)
+		if report.CacheStatus == cacheStatusValid {
+			expiresAt += fmt.Sprintf(" (in %s)", time.Duration(report.TimeRemaining)*time.Second)
+		} else {
+			expiresAt += " (expired)"
+		}
+	}
+	var issuedAt string
+	if report.IssuedAt != nil {
+		issuedAt = report.IssuedAt.Local().Format(time.RFC3339)
+	}
+	verified := "yes"
+	if !report.Verified {
+		verified = "no"
+		if report.Error != "" {
+			verified += " - " + report.Error
+		}
+	}
+
+	field("Role", report.Role)
+	field("Account", account)
+	field("Role name", report.RoleName)
+	field("Session name", report.SessionName)
+	field("Session ARN", report.SessionARN)
+	field("Source identity", report.SourceIdentity)
+	field("Issued at", issuedAt)
+	field("Expires at", expiresAt)
+	field("Source", report.Source)
+	field("Environment", environment)
+	field("Verified", verified)
+	return tw.Flush()
+}
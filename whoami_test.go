@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jkueh/roo/cachedcredsprovider"
+	"github.com/jkueh/roo/config"
+	"github.com/jkueh/roo/roo"
+)
+
+func testWhoamiClient() (*roo.Client, cachedcredsprovider.Store) {
+	conf := &config.Config{Roles: []config.RoleConfig{
+		{Name: "prod-readonly", ARN: "arn:aws:iam::000000000000:role/ReadOnly", AccountName: "acme-prod"},
+		{Name: "test-developer", ARN: "arn:aws:iam::111111111111:role/Developer", IsDefault: true},
+	}}
+	store := cachedcredsprovider.NewMemoryStore()
+	store.Put("000000000000-ReadOnly", &cachedcredsprovider.CachedCredentials{
+		RoleARN:    "arn:aws:iam::000000000000:role/ReadOnly",
+		SessionARN: "arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1",
+		IssuedAt:   time.Now().Add(-time.Minute),
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Values:     cachedcredsprovider.Credentials{AccessKeyID: "ASIAROO", SecretAccessKey: "secret"},
+	})
+	return roo.New(conf, store, nil), store
+}
+
+func TestWhoamiEnvironment(t *testing.T) {
+	client, store := testWhoamiClient()
+	env := map[string]string{"AWS_ACCESS_KEY_ID": "ASIAROO", "AWS_SECRET_ACCESS_KEY": "secret"}
+	report, creds, err := findWhoamiCredentials(client, store, "", func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("Unable to find credentials: %s", err)
+	}
+	if creds == nil || creds.AccessKeyID != "ASIAROO" {
+		t.Errorf("Expected the credentials from the environment, got %+v", creds)
+	}
+	if report.Source != whoamiSourceEnvironment || report.Environment != environmentRoo {
+		t.Errorf("Expected roo-managed credentials from the environment, got %s/%s", report.Source, report.Environment)
+	}
+	if report.Role != "prod-readonly" || report.SessionName != "roo-1" || report.CacheStatus != cacheStatusValid {
+		t.Errorf("Expected the cached session details, got %+v", report)
+	}
+
+	env["AWS_ACCESS_KEY_ID"] = "AKIAOTHER"
+	report, _, _ = findWhoamiCredentials(client, store, "", func(key string) string { return env[key] })
+	if report.Environment != environmentOther || report.Role != "" {
+		t.Errorf("Expected credentials that roo didn't issue, got %+v", report)
+	}
+}
+
+func TestFindCachedCredentialsSkipsMFASessions(t *testing.T) {
+	_, store := testWhoamiClient()
+	store.Put("session_corporate", &cachedcredsprovider.CachedCredentials{
+		ExpiresAt: time.Now().Add(time.Hour),
+		Values:    cachedcredsprovider.Credentials{AccessKeyID: "ASIASESSION", SecretAccessKey: "secret"},
+	})
+	if key, cached := findCachedCredentials(store, "ASIASESSION"); cached != nil {
+		t.Errorf("Expected the MFA session not to be reported as a role, got %s", key)
+	}
+	if key, cached := findCachedCredentials(store, "ASIAROO"); cached == nil || key != "000000000000-ReadOnly" {
+		t.Errorf("Expected the role's cached credentials, got %s", key)
+	}
+}
+
+func TestWhoamiRole(t *testing.T) {
+	client, store := testWhoamiClient()
+	getenv := func(key string) string { return map[string]string{"AWS_ACCESS_KEY_ID": "ASIAROO"}[key] }
+	report, creds, err := findWhoamiCredentials(client, store, "prod-readonly", getenv)
+	if err != nil {
+		t.Fatalf("Unable to find credentials: %s", err)
+	}
+	if report.Source != whoamiSourceCache || creds == nil || creds.SecretAccessKey != "secret" {
+		t.Errorf("Expected the cached credentials for the role, got %s: %+v", report.Source, creds)
+	}
+	if report.EnvironmentRole != "prod-readonly" {
+		t.Errorf("Expected the environment to be reported as the role's session, got %s", report.EnvironmentRole)
+	}
+
+	// The default role doesn't have any cached credentials.
+	report, creds, err = findWhoamiCredentials(client, store, "", func(string) string { return "" })
+	if err != nil || creds != nil || report.Role != "test-developer" || report.CacheStatus != cacheStatusNone {
+		t.Errorf("Expected no credentials for the default role, got %+v, %v", report, err)
+	}
+}
+
+func TestVerifyWhoami(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_REGION", "us-east-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		result := "<Arn>arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1</Arn><UserId>AROAFAKE:roo-1</UserId>" +
+			"<Account>000000000000</Account>"
+		if action == "ListAccountAliases" {
+			// Fall back to the account name in the config file.
+			result = "<AccountAliases></AccountAliases><IsTruncated>false</IsTruncated>"
+		}
+		fmt.Fprintf(w, "<%[1]sResponse><%[1]sResult>%[2]s</%[1]sResult></%[1]sResponse>", action, result)
+	}))
+	defer server.Close()
+
+	client, store := testWhoamiClient()
+	client.STSEndpoint = server.URL
+	client.IAMEndpoint = server.URL
+	report, creds, err := findWhoamiCredentials(client, store, "prod-readonly", func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyWhoami(context.Background(), client, report, *creds)
+	if !report.Verified || report.AccountID != "000000000000" || report.AccountAlias != "acme-prod" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestParseAssumedRoleARN(t *testing.T) {
+	roleName, sessionName := parseAssumedRoleARN("arn:aws:sts::000000000000:assumed-role/ReadOnly/roo-1")
+	if roleName != "ReadOnly" || sessionName != "roo-1" {
+		t.Errorf("Unexpected role and session names: %s, %s", roleName, sessionName)
+	}
+	if roleName, sessionName := parseAssumedRoleARN("arn:aws:iam::000000000000:user/someone"); roleName != "" ||
+		sessionName != "" {
+		t.Errorf("Expected nothing for a user ARN, got %s, %s", roleName, sessionName)
+	}
+}